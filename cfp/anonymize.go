@@ -0,0 +1,90 @@
+package cfp
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// scrubbed replaces the identifying content removed from texts.
+const scrubbed = "███"
+
+// identifyingPatterns match the content identifying anyone, whoever the speakers are.
+var identifyingPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`[\w.+-]+@[\w-]+(\.[\w-]+)+`), // emails
+	regexp.MustCompile(`https?://\S+`),               // links to slides, videos or profiles
+	regexp.MustCompile(`@\w+`),                       // social handles
+	regexp.MustCompile(`\+?\d[\d .-]{7,}\d`),         // phone numbers
+}
+
+// Anonymize returns the proposal without anything identifying its speakers, for a blind deliberation.
+// Speakers are removed, and their names, companies and cities are scrubbed from the title, texts and organizer messages
+// of the proposal, as well as emails, links, social handles and phone numbers. The private message is dropped if
+// dropMessage is true.
+func (p Proposal) Anonymize(dropMessage bool) Proposal {
+	terms := make([]string, 0)
+	for _, speaker := range p.Speakers {
+		terms = append(terms, speaker.Name, speaker.Company)
+		terms = append(terms, strings.Fields(speaker.Name)...)
+		if speaker.City != "" {
+			city, _, _ := strings.Cut(speaker.City, ",")
+			terms = append(terms, city)
+		}
+	}
+	// longer terms first, so that full names are scrubbed at once
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+
+	anonymous := p
+	anonymous.Speakers = nil
+	anonymous.Title = scrub(p.Title, terms)
+	anonymous.Abstract = scrub(p.Abstract, terms)
+	anonymous.References = scrub(p.References, terms)
+	anonymous.PrivateMessage = scrub(p.PrivateMessage, terms)
+	if dropMessage {
+		anonymous.PrivateMessage = ""
+	}
+	anonymous.OrganizerMessages = make([]string, 0, len(p.OrganizerMessages))
+	for _, message := range p.OrganizerMessages {
+		anonymous.OrganizerMessages = append(anonymous.OrganizerMessages, scrub(message, terms))
+	}
+	return anonymous
+}
+
+func scrub(text string, terms []string) string {
+	for _, pattern := range identifyingPatterns {
+		text = pattern.ReplaceAllString(text, scrubbed)
+	}
+	for _, term := range terms {
+		// too short terms, such as initials, would scrub parts of words
+		if utf8.RuneCountInString(term) < 3 {
+			continue
+		}
+		text = scrubWord(text, term)
+	}
+	return text
+}
+
+// scrubWord replaces the occurrences of a term which are whole words, ignoring case.
+func scrubWord(text, term string) string {
+	re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(term))
+	var b strings.Builder
+	last := 0
+	for _, match := range re.FindAllStringIndex(text, -1) {
+		before, _ := utf8.DecodeLastRuneInString(text[:match[0]])
+		after, _ := utf8.DecodeRuneInString(text[match[1]:])
+		if isWordRune(before) || isWordRune(after) {
+			continue
+		}
+		b.WriteString(text[last:match[0]])
+		b.WriteString(scrubbed)
+		last = match[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
@@ -1,25 +1,34 @@
 package cfp
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/bdxio/cfp-to-trello/common"
 	"github.com/bdxio/cfp-to-trello/geo"
 )
 
-const stateSubmitted = "submitted"
+// States of the proposals, as named by the first Conference-Hall API.
+const (
+	StateSubmitted = "submitted"
+	StateAccepted  = "accepted"
+	StateConfirmed = "confirmed" // accepted and confirmed by the speakers
+	StateDeclined  = "declined"  // accepted but declined by the speakers
+	StateRejected  = "rejected"
+)
 
 type Event struct {
 	Name       string
 	Proposals  []Proposal
 	Formats    []string
 	Categories []string
+	Warnings   []string // oddities found while parsing, which didn't prevent it
 }
 
 func (e Event) GetProposals(format string) []Proposal {
@@ -45,15 +54,113 @@ func (e Event) GetProposalsByCategory(format string) map[string][]Proposal {
 	return m
 }
 
+// Filter selects proposals of an event, each empty criterion selecting all proposals.
+type Filter struct {
+	States     []string
+	Formats    []string
+	Categories []string
+	Languages  []string
+
+	LanguageMapping LanguageMapping // mapping of Languages to flags, the default one if empty
+}
+
+// Filter returns the event restricted to the proposals selected by the filter.
+// Formats and categories are restricted too, so that only the given formats are imported.
+// Values matching no format, category or state of the event are rejected, as they are most likely typos.
+func (e Event) Filter(f Filter) (Event, error) {
+	if format, ok := unknownValue(f.Formats, e.Formats); ok {
+		return Event{}, fmt.Errorf("%s is not a format of the event", format)
+	}
+	if category, ok := unknownValue(f.Categories, e.Categories); ok {
+		return Event{}, fmt.Errorf("%s is not a category of the event", category)
+	}
+	states := []string{StateSubmitted, StateAccepted, StateConfirmed, StateDeclined, StateRejected}
+	for _, proposal := range e.Proposals {
+		// other platforms may have states of their own
+		states = append(states, proposal.State)
+	}
+	if state, ok := unknownValue(f.States, states); ok {
+		return Event{}, fmt.Errorf("%s is not a known state", state)
+	}
+	mapping := f.LanguageMapping
+	if len(mapping.Languages) == 0 {
+		mapping = DefaultLanguageMapping()
+	}
+	languages := make([]string, 0, len(f.Languages))
+	for _, language := range f.Languages {
+		l, ok := mapping.Normalize(language)
+		if !ok {
+			return Event{}, fmt.Errorf("%s is not a known language", language)
+		}
+		languages = append(languages, strings.Split(l, "/")...)
+	}
+
+	proposals := make([]Proposal, 0, len(e.Proposals))
+	for _, proposal := range e.Proposals {
+		if !matches(f.States, proposal.State) || !matches(f.Formats, proposal.Format) || !matches(f.Categories, proposal.Category) {
+			continue
+		}
+		if !matchesAny(languages, strings.Split(proposal.Language, "/")) {
+			continue
+		}
+		proposals = append(proposals, proposal)
+	}
+
+	formats := make([]string, 0, len(e.Formats))
+	for _, format := range e.Formats {
+		if matches(f.Formats, format) {
+			formats = append(formats, format)
+		}
+	}
+	categories := make([]string, 0, len(e.Categories))
+	for _, category := range e.Categories {
+		if matches(f.Categories, category) {
+			categories = append(categories, category)
+		}
+	}
+	return Event{Name: e.Name, Proposals: proposals, Formats: formats, Categories: categories, Warnings: e.Warnings}, nil
+}
+
+// unknownValue returns the first value matching none of the known ones.
+func unknownValue(values, known []string) (string, bool) {
+	for _, v := range values {
+		if len(known) == 0 || !matches(known, v) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// matches returns true if the value is in the allowed ones, ignoring case, or if any value is allowed.
+func matches(allowed []string, value string) bool {
+	return matchesAny(allowed, []string{value})
+}
+
+func matchesAny(allowed []string, values []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		for _, v := range values {
+			if strings.EqualFold(a, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 type Proposal struct {
 	ID                string
 	Title             string
+	State             string
 	Category          string
 	Format            string
 	Abstract          string
 	AudienceLevel     string
 	Language          string
-	Speakers          string
+	Speakers          []ProposalSpeaker
+	References        string
 	PrivateMessage    string
 	Rating            float64
 	Loves             int
@@ -61,12 +168,76 @@ type Proposal struct {
 	OrganizerMessages []string
 }
 
+// SpeakersLabel returns the labels of the speakers of the proposal, separated by slashes.
+func (p Proposal) SpeakersLabel() string {
+	labels := make([]string, 0, len(p.Speakers))
+	for _, speaker := range p.Speakers {
+		labels = append(labels, speaker.Label())
+	}
+	return strings.Join(labels, " / ")
+}
+
+// ProposalSpeaker is a speaker of a proposal, with what organizers need to know about them during deliberations.
+type ProposalSpeaker struct {
+	ID         string
+	Name       string
+	Company    string
+	City       string // empty if the speaker has no address
+	Local      bool   // true if the speaker lives in Gironde area
+	Bio        string
+	Twitter    string // URL of the Twitter profile
+	GitHub     string // URL of the GitHub profile
+	PhotoURL   string
+	References string
+}
+
+// Label returns the name of the speaker followed by their city and company, e.g. "Jane Doe - Lormont, France 🍷 (ACME)".
+func (s ProposalSpeaker) Label() string {
+	label := s.Name + " -"
+	if s.City == "" {
+		label += " 🗺️"
+	} else {
+		label += " " + s.City
+		// Speaker in Gironde area should be identified clearly, a glass of wine should do the trick.
+		if s.Local {
+			label += " 🍷"
+		}
+	}
+	if s.Company != "" {
+		label += " (" + s.Company + ")"
+	}
+	return label
+}
+
+// proposalIDRegexp finds a proposal ID in its marker, or in its Conference-Hall URL for cards imported before markers.
+var proposalIDRegexp = regexp.MustCompile("🆔 `([^`]+)`|/organizer/event/[^/]+/proposals/([^/)\\s]+)")
+
+// ProposalMarker returns the marker identifying a proposal in a text, e.g. a Trello card description.
+func ProposalMarker(id string) string {
+	return fmt.Sprintf("🆔 `%s`", id)
+}
+
+// FindProposalID returns the ID of the proposal identified in a text by its marker or by its Conference-Hall URL.
+func FindProposalID(text string) (string, bool) {
+	matches := proposalIDRegexp.FindStringSubmatch(text)
+	switch {
+	case matches == nil:
+		return "", false
+	case matches[1] != "":
+		return matches[1], true
+	default:
+		return matches[2], true
+	}
+}
+
 type Export struct {
 	Name       string     `json:"name"`
 	Categories []Category `json:"categories"`
 	Formats    []Format   `json:"formats"`
 	Talks      []Talk     `json:"talks"`
 	Speakers   []Speaker  `json:"speakers"`
+	// Warnings are the problems found while mapping the export of another platform, reported with the event ones.
+	Warnings []string `json:"-"`
 }
 
 type Category struct {
@@ -89,6 +260,7 @@ type Talk struct {
 	Formats          string            `json:"formats"`    // plural but holds only one format
 	Speakers         []string          `json:"speakers"`
 	Comments         string            `json:"comments"`
+	References       string            `json:"references"`
 	Rating           float64           `json:"rating"`
 	Loves            int               `json:"loves"`
 	Hates            int               `json:"hates"`
@@ -97,7 +269,12 @@ type Talk struct {
 }
 
 func (t Talk) IsSubmitted() bool {
-	return t.State == stateSubmitted
+	return t.State == StateSubmitted
+}
+
+// IsAccepted returns true if the talk has been accepted, whether its speakers confirmed or not.
+func (t Talk) IsAccepted() bool {
+	return t.State == StateAccepted || t.State == StateConfirmed
 }
 
 type OrganizerThread struct {
@@ -112,11 +289,16 @@ type Date struct {
 }
 
 type Speaker struct {
-	UID         string   `json:"uid"`
-	DisplayName string   `json:"displayName"`
-	Company     string   `json:"company"`
-	Address     *Address `json:"address"`
-	Email       string   `json:"email"`
+	UID               string   `json:"uid"`
+	DisplayName       string   `json:"displayName"`
+	Company           string   `json:"company"`
+	Address           *Address `json:"address"`
+	Email             string   `json:"email"`
+	Bio               string   `json:"bio"`
+	SpeakerReferences string   `json:"speakerReferences"`
+	PhotoURL          string   `json:"photoURL"`
+	Twitter           string   `json:"twitter"`
+	GitHub            string   `json:"github"`
 }
 
 type Address struct {
@@ -135,32 +317,64 @@ var audienceLevels = map[string]string{
 	"advanced":     "Avancé",
 }
 
-var languages = map[string]string{
-	"French":                               "🇫🇷",
-	"français":                             "🇫🇷",
-	"Frafra":                               "🇫🇷",
-	"English":                              "🇬🇧",
-	"English or French (any preferences?)": "🇫🇷/🇬🇧",
+// ParseOption configures how a CFP export is parsed.
+type ParseOption func(p *parser)
+
+// WithLanguageMapping sets the mapping of the languages of proposals to flags.
+func WithLanguageMapping(m LanguageMapping) ParseOption {
+	return func(p *parser) {
+		p.languages = m
+	}
 }
 
-func Parse(path string, locate geo.Locator) (Event, error) {
+type parser struct {
+	languages LanguageMapping
+}
+
+// Parse parses the CFP export JSON file found at path.
+func Parse(path string, locate geo.Locator, opts ...ParseOption) (Event, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return Event{}, err
 	}
 	defer f.Close()
 
-	data, err := io.ReadAll(f)
+	log.Printf("Parsing CFP export from %s...", path)
+	return ParseReader(f, locate, opts...)
+}
+
+// ReadExport reads the CFP export JSON file found at path, without converting it into an event.
+func ReadExport(path string) (Export, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return Event{}, err
+		return Export{}, err
+	}
+	defer f.Close()
+
+	var export Export
+	if err := common.UnmarshalBody(f, &export); err != nil {
+		return Export{}, err
 	}
+	return export, nil
+}
 
+// ParseReader parses a CFP export in JSON read from r.
+func ParseReader(r io.Reader, locate geo.Locator, opts ...ParseOption) (Event, error) {
 	var export Export
-	if err := json.Unmarshal(data, &export); err != nil {
+	if err := common.UnmarshalBody(r, &export); err != nil {
 		return Event{}, err
 	}
+	return ParseExport(export, locate, opts...)
+}
+
+// ParseExport converts a CFP export into an event.
+// Unknown languages don't fail the parsing, they are flagged as unknown and reported in the warnings of the event.
+func ParseExport(export Export, locate geo.Locator, opts ...ParseOption) (Event, error) {
+	p := parser{languages: DefaultLanguageMapping()}
+	for _, opt := range opts {
+		opt(&p)
+	}
 
-	log.Printf("Parsing CFP export from %s...", path)
 	categories := getCategories(export.Categories)
 	formats := getFormats(export.Formats)
 	speakers, err := getSpeakers(export.Speakers, locate)
@@ -169,38 +383,53 @@ func Parse(path string, locate geo.Locator) (Event, error) {
 	}
 
 	proposals := make([]Proposal, 0, len(export.Talks))
+	warnings := append([]string(nil), export.Warnings...)
 	for _, talk := range export.Talks {
-		language, err := parseLanguage(talk.Language)
-		if err != nil {
-			return Event{}, err
+		language, ok := p.languages.Normalize(talk.Language)
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("unknown language %q for proposal %q (%s)", talk.Language, strings.TrimSpace(talk.Title), talk.ID))
 		}
-		speakerLabels := make([]string, 0, len(talk.Speakers))
-		for _, speaker := range talk.Speakers {
-			speakerLabel, ok := speakers[speaker]
+		proposalSpeakers := make([]ProposalSpeaker, 0, len(talk.Speakers))
+		for _, uid := range talk.Speakers {
+			speaker, ok := speakers[uid]
 			if !ok {
-				return Event{}, fmt.Errorf("speaker %s not found in speakers map", speaker)
+				return Event{}, fmt.Errorf("speaker %s not found in speakers map", uid)
 			}
-			speakerLabels = append(speakerLabels, speakerLabel)
+			proposalSpeakers = append(proposalSpeakers, speaker)
 		}
-		p := Proposal{
+		proposal := Proposal{
 			ID:                talk.ID,
 			Title:             strings.Trim(talk.Title, " "),
+			State:             talk.State,
 			Category:          categories[talk.Categories],
 			Format:            formats[talk.Formats],
 			Abstract:          talk.Abstract,
 			AudienceLevel:     audienceLevels[talk.Level],
 			Language:          language,
-			Speakers:          strings.Join(speakerLabels, " / "),
+			Speakers:          proposalSpeakers,
+			References:        talk.References,
 			PrivateMessage:    talk.Comments,
 			Rating:            talk.Rating,
 			Loves:             talk.Loves,
 			Hates:             talk.Hates,
 			OrganizerMessages: parseOrganizerMessages(talk.OrganizersThread),
 		}
-		proposals = append(proposals, p)
+		proposals = append(proposals, proposal)
 	}
 
-	return Event{Name: export.Name, Proposals: proposals, Formats: getValues(formats), Categories: getValues(categories)}, nil
+	return Event{Name: export.Name, Proposals: proposals, Formats: getValues(formats), Categories: getValues(categories), Warnings: warnings}, nil
+}
+
+// missingWarnings returns a warning for a talk without format or category, as it is left out of the boards or lists.
+func missingWarnings(talk Talk) []string {
+	var warnings []string
+	if talk.Formats == "" {
+		warnings = append(warnings, fmt.Sprintf("no format for proposal %q (%s)", strings.TrimSpace(talk.Title), talk.ID))
+	}
+	if talk.Categories == "" {
+		warnings = append(warnings, fmt.Sprintf("no category for proposal %q (%s)", strings.TrimSpace(talk.Title), talk.ID))
+	}
+	return warnings
 }
 
 func getCategories(categories []Category) map[string]string {
@@ -219,48 +448,52 @@ func getFormats(formats []Format) map[string]string {
 	return m
 }
 
-func getSpeakers(speakers []Speaker, locate geo.Locator) (map[string]string, error) {
-	m := make(map[string]string)
+func getSpeakers(speakers []Speaker, locate geo.Locator) (map[string]ProposalSpeaker, error) {
+	m := make(map[string]ProposalSpeaker)
 	for _, speaker := range speakers {
-		speakerLabel := speaker.DisplayName
-		if speakerLabel == "" {
-			speakerLabel = speaker.Email
+		s := ProposalSpeaker{
+			ID:         speaker.UID,
+			Name:       speaker.DisplayName,
+			Company:    speaker.Company,
+			Bio:        speaker.Bio,
+			Twitter:    profileURL(speaker.Twitter, "https://twitter.com/"),
+			GitHub:     profileURL(speaker.GitHub, "https://github.com/"),
+			PhotoURL:   speaker.PhotoURL,
+			References: speaker.SpeakerReferences,
 		}
-		speakerLabel += " -"
-
-		if speaker.Address == nil {
-			speakerLabel += " 🗺️"
-		} else {
+		if s.Name == "" {
+			s.Name = speaker.Email
+		}
+		if speaker.Address != nil {
 			location, err := locate(speaker.Address.LatLng.Lat, speaker.Address.LatLng.Lng, speaker.Address.FormattedAddress)
 			if err != nil {
 				return nil, err
 			}
-			speakerLabel += " " + location.City
-			// Speaker in Gironde area should be identified clearly, a glass of wine should do the trick.
-			if location.IsInGironde() {
-				speakerLabel += " 🍷"
-			}
+			s.City = location.City
+			s.Local = location.IsInGironde()
 		}
-
-		if speaker.Company != "" {
-			speakerLabel += " (" + speaker.Company + ")"
-		}
-
-		m[speaker.UID] = speakerLabel
+		m[speaker.UID] = s
 	}
 	return m, nil
 }
 
-func parseLanguage(language string) (string, error) {
-	// French as default if empty language
-	if language == "" || strings.Trim(language, " ") == "" {
-		return languages["French"], nil
+// profileURL normalizes a social profile given by a speaker, either as a URL, a domain-less path or a handle,
+// into the URL of the profile, e.g. "@jdoe" into "https://twitter.com/jdoe".
+func profileURL(profile, baseURL string) string {
+	fields := strings.Fields(profile)
+	if len(fields) == 0 {
+		return ""
 	}
-	l, ok := languages[language]
-	if !ok {
-		return "", fmt.Errorf("%s is not a known language", language)
+	// anything following the profile is a comment, e.g. "https://github.com/jdoe (almost inactive)"
+	profile = fields[0]
+	switch {
+	case strings.HasPrefix(profile, "https://"), strings.HasPrefix(profile, "http://"):
+		return profile
+	case strings.Contains(profile, "/"):
+		return "https://" + profile
+	default:
+		return baseURL + strings.TrimPrefix(profile, "@")
 	}
-	return l, nil
 }
 
 func parseOrganizerMessages(threads []OrganizerThread) []string {
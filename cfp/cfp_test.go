@@ -1,6 +1,11 @@
 package cfp
 
 import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -175,7 +180,7 @@ func TestParse(t *testing.T) {
 			assert.Equal(t, tc.abstract, proposal.Abstract)
 			assert.Equal(t, tc.audienceLevel, proposal.AudienceLevel)
 			assert.Equal(t, tc.language, proposal.Language)
-			assert.Equal(t, tc.speakers, proposal.Speakers)
+			assert.Equal(t, tc.speakers, proposal.SpeakersLabel())
 			assert.Equal(t, tc.privateMessage, proposal.PrivateMessage)
 			assert.Equal(t, tc.rating, proposal.Rating)
 			assert.Equal(t, tc.loves, proposal.Loves)
@@ -219,3 +224,545 @@ func TestEvent_GetProposalsByCategory(t *testing.T) {
 	proposals = event.GetProposalsByCategory("Format 2")
 	assert.Empty(t, proposals)
 }
+
+func TestParseExport_FromConferenceHall(t *testing.T) {
+	srv, stop := NewConferenceHallServer("12345", "67890", "testdata/export.json")
+	t.Cleanup(stop)
+	client := NewConferenceHallClient(
+		WithURL(srv.URL),
+		WithEventID("12345"),
+		WithAPIKey("67890"),
+		WithHTTPClient(srv.Client),
+	)
+
+	export, err := client.GetExport()
+	require.NoError(t, err)
+	event, err := ParseExport(export, geo.FakeLocate)
+
+	require.NoError(t, err)
+	expected, err := Parse("testdata/export.json", geo.FakeLocate)
+	require.NoError(t, err)
+	assert.Equal(t, expected, event)
+}
+
+func TestParseExport_FromConferenceHallV2(t *testing.T) {
+	leala := ProposalSpeaker{
+		ID:       "s1",
+		Name:     "Leala Simard",
+		Company:  "Company 1",
+		Bio:      "A speaker",
+		PhotoURL: "https://example.com/leala.png",
+	}
+	dev := ProposalSpeaker{ID: "s2", Name: "Dev from UK"}
+	srv, stop := NewConferenceHallServerV2("12345", "67890", "testdata/export_v2.json")
+	t.Cleanup(stop)
+	client := NewConferenceHallClient(
+		WithURL(srv.URL),
+		WithEventID("12345"),
+		WithAPIKey("67890"),
+		WithHTTPClient(srv.Client),
+		WithAPIVersion(APIv2),
+	)
+
+	export, err := client.GetExport()
+	require.NoError(t, err)
+	// free text locations must not be taken for coordinates
+	event, err := ParseExport(export, func(lat, lon float64, address string) (geo.Location, error) {
+		return geo.Location{}, fmt.Errorf("%s located at %f,%f", address, lat, lon)
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Awesome Conference 2042", event.Name)
+	assert.Equal(t, []string{"Format 1", "Format 2"}, event.Formats)
+	assert.Equal(t, []string{"Category 1", "Category 2"}, event.Categories)
+	assert.Equal(
+		t,
+		[]Proposal{
+			{
+				ID:                "p1",
+				Title:             "A beginner talk in category 1",
+				State:             "submitted",
+				Category:          "Category 1",
+				Format:            "Format 1",
+				Abstract:          "An interesting abstract",
+				AudienceLevel:     "Débutant",
+				Language:          "🇫🇷",
+				Speakers:          []ProposalSpeaker{leala},
+				References:        "Some references",
+				Rating:            3.5,
+				Loves:             2,
+				Hates:             1,
+				OrganizerMessages: []string{},
+			},
+			{
+				ID:                "p2",
+				Title:             "An accepted talk in category 2",
+				State:             "confirmed",
+				Category:          "Category 2",
+				Format:            "Format 2",
+				Abstract:          "Another interesting abstract",
+				AudienceLevel:     "Avancé",
+				Language:          "🇫🇷/🇬🇧",
+				Speakers:          []ProposalSpeaker{leala, dev},
+				OrganizerMessages: []string{},
+			},
+			{
+				ID:                "p3",
+				Title:             "A rejected talk",
+				State:             "rejected",
+				Abstract:          "A less interesting abstract",
+				Language:          "🇫🇷",
+				Speakers:          []ProposalSpeaker{dev},
+				OrganizerMessages: []string{},
+			},
+		},
+		event.Proposals,
+	)
+}
+
+func TestExportV2_ToExport_SeveralFormats(t *testing.T) {
+	e := exportV2{
+		Name: "Awesome Conference 2042",
+		Proposals: []proposalV2{
+			{ID: "p1", Title: "A talk", Formats: []string{"Format 1", "Format 2"}, Categories: []string{"Category 1", "Category 2", "Category 3"}},
+			{ID: "p2", Title: "Another talk", Formats: []string{"Format 2"}, Categories: []string{"Category 2"}},
+		},
+	}
+
+	event, err := ParseExport(e.toExport(), geo.FakeLocate)
+
+	require.NoError(t, err)
+	assert.Equal(t, "Format 1", event.Proposals[0].Format)
+	assert.Equal(t, "Category 1", event.Proposals[0].Category)
+	assert.Equal(
+		t,
+		[]string{
+			`formats Format 2 dropped for proposal "A talk" (p1), keeping Format 1`,
+			`categories Category 2, Category 3 dropped for proposal "A talk" (p1), keeping Category 1`,
+		},
+		event.Warnings,
+	)
+}
+
+func TestConferenceHallClient_V2(t *testing.T) {
+	srv, stop := NewConferenceHallServerV2("12345", "67890", "testdata/export_v2.json")
+	t.Cleanup(stop)
+	client := NewConferenceHallClient(
+		WithURL(srv.URL),
+		WithEventID("12345"),
+		WithAPIKey("67890"),
+		WithHTTPClient(srv.Client),
+		WithAPIVersion(APIv2),
+	)
+
+	resp, err := client.Accept(Talk{ID: "p1", Title: "A talk"})
+	require.NoError(t, err)
+	assert.Equal(t, "Proposal with ID p1 is now accepted.", resp)
+	_, err = client.Reject(Talk{ID: "p3", Title: "Another talk"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p1"}, srv.AcceptedIDs)
+	assert.Equal(t, []string{"p3"}, srv.RejectedIDs)
+
+	_, err = NewConferenceHallClient(
+		WithURL(srv.URL),
+		WithEventID("12345"),
+		WithAPIKey("invalid"),
+		WithHTTPClient(srv.Client),
+		WithAPIVersion(APIv2),
+	).GetExport()
+	assert.EqualError(t, err, "error while getting export of event 12345: 401")
+}
+
+func TestSessionizeFile_Event(t *testing.T) {
+	leala := ProposalSpeaker{
+		ID:       "a1b2",
+		Name:     "Leala Simard",
+		Company:  "Company 1",
+		Bio:      "A speaker",
+		Twitter:  "https://twitter.com/LealaSimard",
+		PhotoURL: "https://example.com/leala.png",
+	}
+	dev := ProposalSpeaker{ID: "c3d4", Name: "Dev from UK"}
+	event, err := SessionizeFile{Path: "testdata/sessionize.json", EventName: "Awesome Meetup 2042"}.Event(geo.FakeLocate)
+
+	require.NoError(t, err)
+	assert.Equal(t, "Awesome Meetup 2042", event.Name)
+	assert.Equal(t, []string{"Talk", "Workshop"}, event.Formats)
+	assert.Equal(t, []string{"Cloud", "Web"}, event.Categories)
+	assert.Equal(
+		t,
+		[]Proposal{
+			{
+				ID:                "101",
+				Title:             "A beginner talk",
+				State:             "submitted",
+				Category:          "Web",
+				Format:            "Talk",
+				Abstract:          "An interesting abstract",
+				AudienceLevel:     "Débutant",
+				Language:          "🇫🇷",
+				Speakers:          []ProposalSpeaker{leala},
+				OrganizerMessages: []string{},
+			},
+			{
+				ID:                "102",
+				Title:             "An accepted workshop",
+				State:             "accepted",
+				Category:          "Cloud",
+				Format:            "Workshop",
+				Abstract:          "Another interesting abstract",
+				AudienceLevel:     "Avancé",
+				Language:          "🇬🇧",
+				Speakers:          []ProposalSpeaker{leala, dev},
+				OrganizerMessages: []string{},
+			},
+		},
+		event.Proposals,
+	)
+	assert.Empty(t, event.Warnings)
+}
+
+func TestSessionize_MissingFormatAndCategory(t *testing.T) {
+	export := sessionizeExport{
+		Sessions: []sessionizeSession{
+			{ID: "101", Title: "A talk without track", CategoryItems: []int{1}},
+			{ID: "102", Title: "A session without anything"},
+		},
+		Categories: []sessionizeCategory{
+			{Title: "Session format", Items: []struct {
+				ID   int    `json:"id"`
+				Name string `json:"name"`
+			}{{ID: 1, Name: "Talk"}}},
+		},
+	}
+
+	event, err := ParseExport(export.toExport("Awesome Meetup 2042"), geo.FakeLocate)
+
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			`no category for proposal "A talk without track" (101)`,
+			`no format for proposal "A session without anything" (102)`,
+			`no category for proposal "A session without anything" (102)`,
+		},
+		event.Warnings,
+	)
+}
+
+func TestPretalxFile_Event(t *testing.T) {
+	leala := ProposalSpeaker{ID: "XYZ123", Name: "Leala Simard", Bio: "A speaker", PhotoURL: "https://example.com/leala.png"}
+	dev := ProposalSpeaker{ID: "UVW456", Name: "Dev from UK"}
+	event, err := PretalxFile{Path: "testdata/pretalx.json", EventName: "Awesome Meetup 2042"}.Event(geo.FakeLocate)
+
+	require.NoError(t, err)
+	assert.Equal(t, "Awesome Meetup 2042", event.Name)
+	assert.Equal(t, []string{"Talk", "Workshop"}, event.Formats)
+	assert.Equal(t, []string{"Web"}, event.Categories)
+	assert.Equal(
+		t,
+		[]Proposal{
+			{
+				ID:                "ABCDEF",
+				Title:             "A beginner talk",
+				State:             "submitted",
+				Category:          "Web",
+				Format:            "Talk",
+				Abstract:          "An interesting abstract\n\nMore details",
+				Language:          "🇫🇷",
+				Speakers:          []ProposalSpeaker{leala},
+				PrivateMessage:    "A private message",
+				OrganizerMessages: []string{},
+			},
+			{
+				ID:                "GHIJKL",
+				Title:             "An accepted workshop",
+				State:             "accepted",
+				Format:            "Workshop",
+				Abstract:          "Another interesting abstract",
+				Language:          "🇬🇧",
+				Speakers:          []ProposalSpeaker{leala, dev},
+				OrganizerMessages: []string{},
+			},
+		},
+		event.Proposals,
+	)
+	assert.Equal(t, []string{`no category for proposal "An accepted workshop" (GHIJKL)`}, event.Warnings)
+}
+
+func TestConferenceHallFile_Event(t *testing.T) {
+	var source Source = ConferenceHallFile{Path: "testdata/export.json"}
+
+	event, err := source.Event(geo.FakeLocate)
+
+	require.NoError(t, err)
+	expected, err := Parse("testdata/export.json", geo.FakeLocate)
+	require.NoError(t, err)
+	assert.Equal(t, expected, event)
+}
+
+func TestConferenceHallFile_EventV2(t *testing.T) {
+	var source Source = ConferenceHallFile{Path: "testdata/export_v2.json", Version: APIv2}
+
+	event, err := source.Event(geo.FakeLocate)
+
+	require.NoError(t, err)
+	srv, stop := NewConferenceHallServerV2("12345", "67890", "testdata/export_v2.json")
+	t.Cleanup(stop)
+	expected, err := NewConferenceHallClient(
+		WithURL(srv.URL),
+		WithEventID("12345"),
+		WithAPIKey("67890"),
+		WithHTTPClient(srv.Client),
+		WithAPIVersion(APIv2),
+	).Event(geo.FakeLocate)
+	require.NoError(t, err)
+	assert.Equal(t, expected, event)
+	assert.Equal(t, []string{"Format 1", "Format 2"}, event.Formats)
+}
+
+func TestConferenceHallClient_RedactAPIKey(t *testing.T) {
+	srv, stop := NewConferenceHallServer("12345", "67890", "testdata/export.json")
+	stop()
+	client := NewConferenceHallClient(
+		WithURL(srv.URL),
+		WithEventID("12345"),
+		WithAPIKey("67890"),
+		WithHTTPClient(srv.Client),
+	)
+
+	_, err := client.GetExport()
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "67890")
+	_, err = client.Accept(Talk{ID: "1", Title: "A talk"})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "67890")
+	assert.Contains(t, err.Error(), srv.URL+"/api/v1/proposal/12345/1/accept")
+}
+
+func TestConferenceHallClient_GetExport_InvalidAPIKey(t *testing.T) {
+	srv, stop := NewConferenceHallServer("12345", "67890", "testdata/export.json")
+	t.Cleanup(stop)
+	client := NewConferenceHallClient(
+		WithURL(srv.URL),
+		WithEventID("12345"),
+		WithAPIKey("invalid"),
+		WithHTTPClient(srv.Client),
+	)
+
+	_, err := client.GetExport()
+
+	assert.EqualError(t, err, "error while getting export of event 12345: 401")
+}
+
+func TestConferenceHallClient_Accept_DryRun(t *testing.T) {
+	srv, stop := NewConferenceHallServer("12345", "67890", "testdata/export.json")
+	t.Cleanup(stop)
+	client := NewConferenceHallClient(
+		WithURL(srv.URL),
+		WithEventID("12345"),
+		WithAPIKey("67890"),
+		WithHTTPClient(srv.Client),
+		WithDryRun(true),
+	)
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	_, err := client.Accept(Talk{ID: "6grkSZ4ArcYr8BZfcw0o", Title: "A talk"})
+
+	require.NoError(t, err)
+	assert.Empty(t, srv.AcceptedIDs)
+	assert.Contains(t, logs.String(), "/api/v1/proposal/12345/6grkSZ4ArcYr8BZfcw0o/accept")
+	assert.NotContains(t, logs.String(), "67890")
+}
+
+func TestEvent_Filter(t *testing.T) {
+	event, err := Parse("testdata/export.json", geo.FakeLocate)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		filter     Filter
+		ids        []string
+		formats    []string
+		categories []string
+	}{
+		{
+			name:       "No filter",
+			filter:     Filter{},
+			ids:        []string{"6grkSZ4ArcYr8BZfcw0o", "tsVw51wQQatiEsWzmWfx", "bSKbIciG4jCWk37vrTEp", "Hj2ZNh7ydvOnpg9TBHeL", "kZvDMmIaTnrFxGjJycqx", "tzdLHxKDtVUXcJLd66TN", "dghzra8K2TfMYnBDjUEb", "xdUotyrnjlJ0XiIUZasR"},
+			formats:    []string{"Format 1", "Format 2"},
+			categories: []string{"Category 1", "Category 2"},
+		},
+		{
+			name:       "Submitted talks",
+			filter:     Filter{States: []string{"submitted"}},
+			ids:        []string{"6grkSZ4ArcYr8BZfcw0o", "bSKbIciG4jCWk37vrTEp", "Hj2ZNh7ydvOnpg9TBHeL", "kZvDMmIaTnrFxGjJycqx", "tzdLHxKDtVUXcJLd66TN", "dghzra8K2TfMYnBDjUEb", "xdUotyrnjlJ0XiIUZasR"},
+			formats:    []string{"Format 1", "Format 2"},
+			categories: []string{"Category 1", "Category 2"},
+		},
+		{
+			name:       "Single format",
+			filter:     Filter{Formats: []string{"format 2"}},
+			ids:        []string{},
+			formats:    []string{"Format 2"},
+			categories: []string{"Category 1", "Category 2"},
+		},
+		{
+			name:       "Categories and languages",
+			filter:     Filter{Categories: []string{"Category 1"}, Languages: []string{"English"}},
+			ids:        []string{"Hj2ZNh7ydvOnpg9TBHeL", "kZvDMmIaTnrFxGjJycqx"},
+			formats:    []string{"Format 1", "Format 2"},
+			categories: []string{"Category 1"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			filtered, err := event.Filter(tc.filter)
+
+			require.NoError(t, err)
+			ids := make([]string, 0, len(filtered.Proposals))
+			for _, proposal := range filtered.Proposals {
+				ids = append(ids, proposal.ID)
+			}
+			assert.Equal(t, tc.ids, ids)
+			assert.Equal(t, tc.formats, filtered.Formats)
+			assert.Equal(t, tc.categories, filtered.Categories)
+			assert.Equal(t, event.Name, filtered.Name)
+		})
+	}
+
+	_, err = event.Filter(Filter{Languages: []string{"Klingon"}})
+	assert.Error(t, err)
+	_, err = event.Filter(Filter{Formats: []string{"Format 1", "Fromat 2"}})
+	assert.EqualError(t, err, "Fromat 2 is not a format of the event")
+	_, err = event.Filter(Filter{Categories: []string{"Category 3"}})
+	assert.EqualError(t, err, "Category 3 is not a category of the event")
+	_, err = event.Filter(Filter{States: []string{"submited"}})
+	assert.EqualError(t, err, "submited is not a known state")
+	_, err = event.Filter(Filter{States: []string{"declined"}})
+	assert.NoError(t, err)
+}
+
+func TestProfileURL(t *testing.T) {
+	tests := []struct {
+		profile  string
+		expected string
+	}{
+		{"", ""},
+		{"@kariangelil", "https://twitter.com/kariangelil"},
+		{"kariangelil", "https://twitter.com/kariangelil"},
+		{"https://twitter.com/LealaSimard", "https://twitter.com/LealaSimard"},
+		{"twitter.com/LealaSimard", "https://twitter.com/LealaSimard"},
+		{"https://twitter.com/LealaSimard (almost inactive)", "https://twitter.com/LealaSimard"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.profile, func(t *testing.T) {
+			assert.Equal(t, tc.expected, profileURL(tc.profile, "https://twitter.com/"))
+		})
+	}
+}
+
+func TestLanguageMapping_Normalize(t *testing.T) {
+	tests := []struct {
+		value string
+		flags string
+		known bool
+	}{
+		{"", "🇫🇷", true},
+		{"French", "🇫🇷", true},
+		{"FRANÇAIS", "🇫🇷", true},
+		{"Frafra", "🇫🇷", true},
+		{"EN", "🇬🇧", true},
+		{"en-US", "🇬🇧", true},
+		{"eng", "🇬🇧", true},
+		{"Englsh", "🇬🇧", true},
+		{"Franch", "🇫🇷", true},
+		{"English or French (any preferences?)", "🇫🇷/🇬🇧", true},
+		{"English, Français, french", "🇫🇷/🇬🇧", true},
+		{"Klingon", "❓", false},
+		{"French / Klingon", "🇫🇷/❓", false},
+		{"es", "❓", false},
+	}
+
+	mapping := DefaultLanguageMapping()
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			flags, known := mapping.Normalize(tc.value)
+			assert.Equal(t, tc.flags, flags)
+			assert.Equal(t, tc.known, known)
+		})
+	}
+}
+
+func TestParseExport_UnknownLanguage(t *testing.T) {
+	export := Export{
+		Name: "Awesome Conference 2042",
+		Talks: []Talk{
+			{ID: "1", Title: "A talk in Spanish", Language: "Español"},
+			{ID: "2", Title: "A talk in French", Language: "fr"},
+		},
+	}
+
+	event, err := ParseExport(export, geo.FakeLocate)
+
+	require.NoError(t, err)
+	assert.Equal(t, "❓", event.Proposals[0].Language)
+	assert.Equal(t, "🇫🇷", event.Proposals[1].Language)
+	assert.Equal(t, []string{`unknown language "Español" for proposal "A talk in Spanish" (1)`}, event.Warnings)
+
+	mapping := DefaultLanguageMapping()
+	mapping.Languages = append(mapping.Languages, LanguageRule{Flag: "🇪🇸", Codes: []string{"es"}, Aliases: []string{"Spanish", "Español"}})
+	event, err = ParseExport(export, geo.FakeLocate, WithLanguageMapping(mapping))
+
+	require.NoError(t, err)
+	assert.Equal(t, "🇪🇸", event.Proposals[0].Language)
+	assert.Empty(t, event.Warnings)
+}
+
+func TestLoadLanguageMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "languages.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"default": "🇬🇧", "languages": [{"flag": "🇬🇧", "codes": ["en"], "aliases": ["English"]}]}`), 0600))
+
+	mapping, err := LoadLanguageMapping(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, LanguageMapping{Default: "🇬🇧", Languages: []LanguageRule{{Flag: "🇬🇧", Codes: []string{"en"}, Aliases: []string{"English"}}}}, mapping)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"default": "🇬🇧", "languages": [{"flag": "🇬🇧", "aliases": ["English"]}, {"flag": "🇺🇸", "aliases": ["english"]}]}`), 0600))
+	_, err = LoadLanguageMapping(path)
+	assert.EqualError(t, err, "invalid language mapping "+path+": english is mapped to both 🇬🇧 and 🇺🇸")
+}
+
+func TestProposal_Anonymize(t *testing.T) {
+	proposal := Proposal{
+		ID:             "1",
+		Title:          "A talk",
+		Abstract:       "Kari Angélil shows how Big Bear Stores scaled, see https://example.com/slides",
+		References:     "Given at a meetup in Lormont by kari",
+		PrivateMessage: "Contact me at kari@example.com or +33 6 12 34 56 78, or @kariangelil. Karine will attend too.",
+		OrganizerMessages: []string{
+			"Kari gave a great talk at Big Bear Stores\n--\n**Orga One** _le 04/08 à 11h44_",
+		},
+		Speakers: []ProposalSpeaker{
+			{ID: "k", Name: "Kari Angélil", Company: "Big Bear Stores", City: "Lormont, France", Local: true},
+		},
+	}
+
+	anonymous := proposal.Anonymize(false)
+
+	assert.Empty(t, anonymous.Speakers)
+	assert.Equal(t, "1", anonymous.ID)
+	assert.Equal(t, "A talk", anonymous.Title)
+	assert.Equal(t, "███ shows how ███ scaled, see ███", anonymous.Abstract)
+	assert.Equal(t, "Given at a meetup in ███ by ███", anonymous.References)
+	assert.Equal(t, "Contact me at ███ or ███, or ███. Karine will attend too.", anonymous.PrivateMessage)
+	assert.Equal(t, []string{"███ gave a great talk at ███\n--\n**Orga One** _le 04/08 à 11h44_"}, anonymous.OrganizerMessages)
+	assert.Empty(t, proposal.Anonymize(true).PrivateMessage)
+	assert.Equal(t, "Why ███ moved to Go", Proposal{Title: "Why Big Bear Stores moved to Go", Speakers: proposal.Speakers}.Anonymize(false).Title)
+	// the proposal itself is left untouched
+	assert.Len(t, proposal.Speakers, 1)
+}
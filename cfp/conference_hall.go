@@ -1,7 +1,9 @@
 package cfp
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,12 +17,35 @@ import (
 
 const URL = "https://conference-hall.io"
 
+// APIVersion is the version of Conference-Hall API.
+type APIVersion string
+
+const (
+	// APIv1 is the API of the first Conference-Hall platform, the API key being passed as a query parameter.
+	APIv1 APIVersion = "v1"
+	// APIv2 is the API of the newer Conference-Hall platform, the API key being passed in the X-API-Key header.
+	APIv2 APIVersion = "v2"
+)
+
+// ParseAPIVersion returns the API version named v.
+func ParseAPIVersion(v string) (APIVersion, error) {
+	switch version := APIVersion(v); version {
+	case APIv1, APIv2:
+		return version, nil
+	default:
+		return "", fmt.Errorf("unknown Conference-Hall API version %s", v)
+	}
+}
+
+const apiKeyHeader = "X-API-Key"
+
 type ConferenceHallClient struct {
 	url     string
 	eventID string
 	apiKey  string
 	client  *http.Client
 	dryRun  bool
+	version APIVersion
 }
 
 type ConferenceHallClientOption func(client *ConferenceHallClient)
@@ -55,8 +80,15 @@ func WithDryRun(dryRun bool) ConferenceHallClientOption {
 	}
 }
 
+// WithAPIVersion sets the version of Conference-Hall API, v1 by default.
+func WithAPIVersion(version APIVersion) ConferenceHallClientOption {
+	return func(c *ConferenceHallClient) {
+		c.version = version
+	}
+}
+
 func NewConferenceHallClient(opts ...ConferenceHallClientOption) ConferenceHallClient {
-	client := ConferenceHallClient{client: http.DefaultClient}
+	client := ConferenceHallClient{client: http.DefaultClient, version: APIv1}
 	for _, opt := range opts {
 		opt(&client)
 	}
@@ -64,6 +96,9 @@ func NewConferenceHallClient(opts ...ConferenceHallClientOption) ConferenceHallC
 }
 
 func (c ConferenceHallClient) GetExport() (Export, error) {
+	if c.version == APIv2 {
+		return c.getExportV2()
+	}
 	getURL, err := url.Parse(fmt.Sprintf("%s/api/v1/event/%s", c.url, c.eventID))
 	if err != nil {
 		return Export{}, err
@@ -73,9 +108,12 @@ func (c ConferenceHallClient) GetExport() (Export, error) {
 	getURL.RawQuery = values.Encode()
 	resp, err := c.client.Get(getURL.String())
 	if err != nil {
-		return Export{}, err
+		return Export{}, redactURL(err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Export{}, fmt.Errorf("error while getting export of event %s: %d", c.eventID, resp.StatusCode)
+	}
 	var export Export
 	if err := common.UnmarshalBody(resp.Body, &export); err != nil {
 		return Export{}, err
@@ -91,17 +129,21 @@ const (
 )
 
 func (c ConferenceHallClient) publish(talk Talk, action talkAction) (string, error) {
+	if c.version == APIv2 {
+		return c.publishV2(talk, action)
+	}
 	putURL, err := url.Parse(fmt.Sprintf("%s/api/v1/proposal/%s/%s/%s", c.url, c.eventID, talk.ID, action))
 	if err != nil {
 		return "", err
 	}
-	values := putURL.Query()
-	values.Add("key", c.apiKey)
-	putURL.RawQuery = values.Encode()
 	if c.dryRun {
-		log.Printf("%sing talk %q: %s", action, talk.Title, putURL.String())
+		// the URL is logged before adding the API key, not to leak it
+		log.Printf("%sing talk %q: PUT %s", action, talk.Title, putURL.String())
 		return "ok", nil
 	}
+	values := putURL.Query()
+	values.Add("key", c.apiKey)
+	putURL.RawQuery = values.Encode()
 	req, err := http.NewRequest(http.MethodPut, putURL.String(), nil)
 	if err != nil {
 		return "", err
@@ -109,7 +151,7 @@ func (c ConferenceHallClient) publish(talk Talk, action talkAction) (string, err
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return "", err
+		return "", redactURL(err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
@@ -123,6 +165,16 @@ func (c ConferenceHallClient) publish(talk Talk, action talkAction) (string, err
 	return jsonResp["result"], nil
 }
 
+// redactURL removes the query of the URL of a failed request, as it carries the API key and errors end up in logs and
+// publication reports.
+func redactURL(err error) error {
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		uerr.URL, _, _ = strings.Cut(uerr.URL, "?")
+	}
+	return err
+}
+
 func (c ConferenceHallClient) Accept(talk Talk) (string, error) {
 	return c.publish(talk, talkAccept)
 }
@@ -131,23 +183,91 @@ func (c ConferenceHallClient) Reject(talk Talk) (string, error) {
 	return c.publish(talk, talkReject)
 }
 
+// getExportV2 gets the export of the event from the newer API, and maps it to the schema of the first one.
+func (c ConferenceHallClient) getExportV2() (Export, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/v2/events/%s/proposals", c.url, url.PathEscape(c.eventID)), nil)
+	if err != nil {
+		return Export{}, err
+	}
+	req.Header.Set(apiKeyHeader, c.apiKey)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Export{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Export{}, fmt.Errorf("error while getting export of event %s: %d", c.eventID, resp.StatusCode)
+	}
+	var export exportV2
+	if err := common.UnmarshalBody(resp.Body, &export); err != nil {
+		return Export{}, err
+	}
+	return export.toExport(), nil
+}
+
+var deliberationStatuses = map[talkAction]string{
+	talkAccept: "ACCEPTED",
+	talkReject: "REJECTED",
+}
+
+func (c ConferenceHallClient) publishV2(talk Talk, action talkAction) (string, error) {
+	putURL := fmt.Sprintf("%s/api/v2/events/%s/proposals/%s/deliberation", c.url, url.PathEscape(c.eventID), url.PathEscape(talk.ID))
+	if c.dryRun {
+		log.Printf("%sing talk %q: PUT %s", action, talk.Title, putURL)
+		return "ok", nil
+	}
+	body, err := json.Marshal(map[string]string{"status": deliberationStatuses[action]})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPut, putURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(apiKeyHeader, c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error while %sing talk %s: %d", action, talk.Title, resp.StatusCode)
+	}
+	return fmt.Sprintf("Proposal with ID %s is now %sed.", talk.ID, action), nil
+}
+
 type ConferenceHallServer struct {
 	URL         string
 	Client      *http.Client
 	AcceptedIDs []string
 	RejectedIDs []string
-	eventID     string
-	apiKey      string
-	jsonPath    string
+	// FailingIDs are the IDs of the proposals that can't be published.
+	FailingIDs map[string]bool
+	eventID    string
+	apiKey     string
+	jsonPath   string
+	version    APIVersion
 }
 
 func NewConferenceHallServer(eventID, apiKey, jsonPath string) (*ConferenceHallServer, func()) {
+	return newConferenceHallServer(eventID, apiKey, jsonPath, APIv1)
+}
+
+// NewConferenceHallServerV2 returns a fake of the newer Conference-Hall API, serving the export found at jsonPath.
+func NewConferenceHallServerV2(eventID, apiKey, jsonPath string) (*ConferenceHallServer, func()) {
+	return newConferenceHallServer(eventID, apiKey, jsonPath, APIv2)
+}
+
+func newConferenceHallServer(eventID, apiKey, jsonPath string, version APIVersion) (*ConferenceHallServer, func()) {
 	cfpServer := &ConferenceHallServer{
 		AcceptedIDs: make([]string, 0),
 		RejectedIDs: make([]string, 0),
 		eventID:     eventID,
 		apiKey:      apiKey,
 		jsonPath:    jsonPath,
+		version:     version,
 	}
 	s := httptest.NewTLSServer(cfpServer)
 	cfpServer.URL = s.URL
@@ -156,6 +276,10 @@ func NewConferenceHallServer(eventID, apiKey, jsonPath string) (*ConferenceHallS
 }
 
 func (s *ConferenceHallServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.version == APIv2 {
+		s.serveV2(w, r)
+		return
+	}
 	switch {
 	case r.Method == http.MethodGet && strings.HasPrefix(r.RequestURI, "/api/v1/event/"):
 		s.sendEvent(w, r)
@@ -216,6 +340,10 @@ func (s *ConferenceHallServer) publishEvent(w http.ResponseWriter, r *http.Reque
 		w.Write([]byte("invalid API key"))
 		return
 	}
+	if s.FailingIDs[paths[1]] {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 	if accept {
 		s.AcceptedIDs = append(s.AcceptedIDs, paths[1])
 	} else {
@@ -226,3 +354,54 @@ func (s *ConferenceHallServer) publishEvent(w http.ResponseWriter, r *http.Reque
 	w.Header().Add("Content-Type", "application/json")
 	w.Write([]byte(result))
 }
+
+func (s *ConferenceHallServer) serveV2(w http.ResponseWriter, r *http.Request) {
+	paths := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v2/events/"), "/")
+	switch {
+	case !strings.HasPrefix(r.URL.Path, "/api/v2/events/") || len(paths) < 2 || paths[1] != "proposals":
+		log.Printf("invalid request: %s\n", r.RequestURI)
+		w.WriteHeader(http.StatusNotFound)
+	case paths[0] != s.eventID:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("invalid eventID"))
+	case r.Header.Get(apiKeyHeader) != s.apiKey:
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("invalid API key"))
+	case r.Method == http.MethodGet && len(paths) == 2:
+		data, err := os.ReadFile(s.jsonPath)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.Write(data)
+	case r.Method == http.MethodPut && len(paths) == 4 && paths[3] == "deliberation":
+		s.deliberate(w, r, paths[2])
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (s *ConferenceHallServer) deliberate(w http.ResponseWriter, r *http.Request, proposalID string) {
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if s.FailingIDs[proposalID] {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	switch body.Status {
+	case "ACCEPTED":
+		s.AcceptedIDs = append(s.AcceptedIDs, proposalID)
+	case "REJECTED":
+		s.RejectedIDs = append(s.RejectedIDs, proposalID)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
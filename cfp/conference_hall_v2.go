@@ -0,0 +1,121 @@
+package cfp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// exportV2 is the export of an event by the newer Conference-Hall platform.
+// Speakers are detailed in every proposal, and formats and categories are referenced by name.
+type exportV2 struct {
+	Name      string       `json:"name"`
+	Proposals []proposalV2 `json:"proposals"`
+}
+
+type proposalV2 struct {
+	ID                 string      `json:"id"`
+	Title              string      `json:"title"`
+	Abstract           string      `json:"abstract"`
+	Level              string      `json:"level"`
+	References         string      `json:"references"`
+	DeliberationStatus string      `json:"deliberationStatus"`
+	ConfirmationStatus string      `json:"confirmationStatus"`
+	Formats            []string    `json:"formats"`
+	Categories         []string    `json:"categories"`
+	Languages          []string    `json:"languages"`
+	Speakers           []speakerV2 `json:"speakers"`
+	Review             *reviewV2   `json:"review"`
+}
+
+type speakerV2 struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Bio      string `json:"bio"`
+	Company  string `json:"company"`
+	Location string `json:"location"`
+	Email    string `json:"email"`
+	Picture  string `json:"picture"`
+}
+
+type reviewV2 struct {
+	Average   float64 `json:"average"`
+	Positives int     `json:"positives"`
+	Negatives int     `json:"negatives"`
+}
+
+// state returns the state of the proposal as named by the first Conference-Hall API.
+func (p proposalV2) state() string {
+	switch p.DeliberationStatus {
+	case "ACCEPTED":
+		switch p.ConfirmationStatus {
+		case "CONFIRMED":
+			return StateConfirmed
+		case "DECLINED":
+			return StateDeclined
+		}
+		return StateAccepted
+	case "REJECTED":
+		return StateRejected
+	default:
+		return StateSubmitted
+	}
+}
+
+// toExport maps the export to the schema of the first Conference-Hall API.
+// Formats and categories are identified by their name, speakers by their ID. A talk having a single format and
+// category, only the first ones of a proposal are kept, the others being reported as warnings.
+func (e exportV2) toExport() Export {
+	export := Export{Name: e.Name}
+	formats := make(map[string]struct{})
+	categories := make(map[string]struct{})
+	speakers := make(map[string]struct{})
+	for _, p := range e.Proposals {
+		talk := Talk{
+			ID:         p.ID,
+			Title:      p.Title,
+			State:      p.state(),
+			Level:      strings.ToLower(p.Level),
+			Abstract:   p.Abstract,
+			Language:   strings.Join(p.Languages, ", "),
+			References: p.References,
+		}
+		if len(p.Formats) > 1 {
+			export.Warnings = append(export.Warnings, fmt.Sprintf("formats %s dropped for proposal %q (%s), keeping %s", strings.Join(p.Formats[1:], ", "), strings.TrimSpace(p.Title), p.ID, p.Formats[0]))
+		}
+		if len(p.Categories) > 1 {
+			export.Warnings = append(export.Warnings, fmt.Sprintf("categories %s dropped for proposal %q (%s), keeping %s", strings.Join(p.Categories[1:], ", "), strings.TrimSpace(p.Title), p.ID, p.Categories[0]))
+		}
+		if len(p.Formats) > 0 {
+			talk.Formats = p.Formats[0]
+			if _, ok := formats[talk.Formats]; !ok {
+				formats[talk.Formats] = struct{}{}
+				export.Formats = append(export.Formats, Format{ID: talk.Formats, Name: talk.Formats})
+			}
+		}
+		if len(p.Categories) > 0 {
+			talk.Categories = p.Categories[0]
+			if _, ok := categories[talk.Categories]; !ok {
+				categories[talk.Categories] = struct{}{}
+				export.Categories = append(export.Categories, Category{ID: talk.Categories, Name: talk.Categories})
+			}
+		}
+		if p.Review != nil {
+			talk.Rating = p.Review.Average
+			talk.Loves = p.Review.Positives
+			talk.Hates = p.Review.Negatives
+		}
+		for _, s := range p.Speakers {
+			talk.Speakers = append(talk.Speakers, s.ID)
+			if _, ok := speakers[s.ID]; ok {
+				continue
+			}
+			speakers[s.ID] = struct{}{}
+			// locations are free text without coordinates, which can't be located: speakers are left with an unknown
+			// location rather than a wrong one
+			speaker := Speaker{UID: s.ID, DisplayName: s.Name, Company: s.Company, Email: s.Email, Bio: s.Bio, PhotoURL: s.Picture}
+			export.Speakers = append(export.Speakers, speaker)
+		}
+		export.Talks = append(export.Talks, talk)
+	}
+	return export
+}
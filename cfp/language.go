@@ -0,0 +1,177 @@
+package cfp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// unknownLanguage is the flag of the languages matching no rule of the mapping.
+const unknownLanguage = "❓"
+
+// LanguageMapping normalizes the languages typed by speakers into flags.
+type LanguageMapping struct {
+	Default   string         `json:"default"` // flag of the proposals without language
+	Languages []LanguageRule `json:"languages"`
+}
+
+// LanguageRule maps a language to its flag.
+// Values are matched against the codes and aliases ignoring case, then against the aliases with a few typos allowed.
+type LanguageRule struct {
+	Flag    string   `json:"flag"`
+	Codes   []string `json:"codes"` // ISO 639 codes, e.g. fr or fra, also matching locales such as fr-FR
+	Aliases []string `json:"aliases"`
+}
+
+// DefaultLanguageMapping returns the mapping of the languages found in BDX I/O CFP.
+func DefaultLanguageMapping() LanguageMapping {
+	return LanguageMapping{
+		Default: "🇫🇷",
+		Languages: []LanguageRule{
+			{Flag: "🇫🇷", Codes: []string{"fr", "fra", "fre"}, Aliases: []string{"French", "Français", "Francais", "Française", "Frafra"}},
+			{Flag: "🇬🇧", Codes: []string{"en", "eng"}, Aliases: []string{"English", "Anglais"}},
+		},
+	}
+}
+
+// LoadLanguageMapping reads a language mapping from a JSON file.
+func LoadLanguageMapping(path string) (LanguageMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return LanguageMapping{}, err
+	}
+	var m LanguageMapping
+	if err := json.Unmarshal(data, &m); err != nil {
+		return LanguageMapping{}, err
+	}
+	if err := m.validate(); err != nil {
+		return LanguageMapping{}, fmt.Errorf("invalid language mapping %s: %w", path, err)
+	}
+	return m, nil
+}
+
+func (m LanguageMapping) validate() error {
+	if m.Default == "" {
+		return fmt.Errorf("no default flag")
+	}
+	values := make(map[string]string)
+	for _, rule := range m.Languages {
+		if rule.Flag == "" {
+			return fmt.Errorf("language with aliases %v has no flag", rule.Aliases)
+		}
+		for _, value := range append(append([]string{}, rule.Codes...), rule.Aliases...) {
+			key := strings.ToLower(value)
+			if flag, ok := values[key]; ok && flag != rule.Flag {
+				return fmt.Errorf("%s is mapped to both %s and %s", value, flag, rule.Flag)
+			}
+			values[key] = rule.Flag
+		}
+	}
+	return nil
+}
+
+// languageSeparators split the values holding several languages, e.g. "English or French (any preferences?)".
+var languageSeparators = regexp.MustCompile(`(?i)\s*(?:[,/&+]|\s(?:or|ou|and|et)\s)\s*`)
+
+// languageComments are the remarks put by speakers after a language.
+var languageComments = regexp.MustCompile(`\([^)]*\)`)
+
+// Normalize returns the flags of the languages found in a value, separated by slashes and ordered as in the mapping.
+// Languages matching no rule get the unknown flag, in which case false is returned.
+func (m LanguageMapping) Normalize(value string) (string, bool) {
+	value = strings.TrimSpace(languageComments.ReplaceAllString(value, ""))
+	if value == "" {
+		return m.Default, true
+	}
+
+	found := make(map[string]bool)
+	known := true
+	for _, language := range languageSeparators.Split(value, -1) {
+		if language == "" {
+			continue
+		}
+		flag, ok := m.match(language)
+		if !ok {
+			known = false
+			flag = unknownLanguage
+		}
+		found[flag] = true
+	}
+
+	flags := make([]string, 0, len(found))
+	for _, rule := range m.Languages {
+		if found[rule.Flag] {
+			flags = append(flags, rule.Flag)
+			delete(found, rule.Flag)
+		}
+	}
+	if found[unknownLanguage] {
+		flags = append(flags, unknownLanguage)
+	}
+	return strings.Join(flags, "/"), known
+}
+
+// match returns the flag of a single language.
+func (m LanguageMapping) match(language string) (string, bool) {
+	// locales such as fr-FR or en_US are matched by their language code
+	code, _, _ := strings.Cut(strings.ReplaceAll(language, "_", "-"), "-")
+	for _, rule := range m.Languages {
+		for _, c := range rule.Codes {
+			if strings.EqualFold(c, language) || strings.EqualFold(c, code) {
+				return rule.Flag, true
+			}
+		}
+		for _, alias := range rule.Aliases {
+			if strings.EqualFold(alias, language) {
+				return rule.Flag, true
+			}
+		}
+	}
+
+	// Common variants are one typo away from an alias, shorter values being too ambiguous.
+	lower := strings.ToLower(language)
+	maxDistance := utf8.RuneCountInString(lower) / 4
+	if maxDistance == 0 {
+		return "", false
+	}
+	bestFlag, bestDistance := "", maxDistance+1
+	for _, rule := range m.Languages {
+		for _, alias := range rule.Aliases {
+			if d := levenshtein(lower, strings.ToLower(alias)); d < bestDistance {
+				bestFlag, bestDistance = rule.Flag, d
+			}
+		}
+	}
+	return bestFlag, bestFlag != ""
+}
+
+// levenshtein returns the number of runes to insert, delete or substitute to turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if d := previous[j] + 1; d < current[j] {
+				current[j] = d
+			}
+			if d := current[j-1] + 1; d < current[j] {
+				current[j] = d
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
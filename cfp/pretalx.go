@@ -0,0 +1,136 @@
+package cfp
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/bdxio/cfp-to-trello/geo"
+)
+
+// PretalxFile is a JSON file holding the submissions of a pretalx event, as returned by its submissions API with
+// speakers expanded. The event name is missing from the submissions.
+type PretalxFile struct {
+	Path      string
+	EventName string
+}
+
+type pretalxSubmissions struct {
+	Results []pretalxSubmission `json:"results"`
+}
+
+type pretalxSubmission struct {
+	Code           string           `json:"code"`
+	Title          string           `json:"title"`
+	State          string           `json:"state"`
+	Abstract       string           `json:"abstract"`
+	Description    string           `json:"description"`
+	SubmissionType pretalxText      `json:"submission_type"`
+	Track          pretalxText      `json:"track"`
+	ContentLocale  string           `json:"content_locale"`
+	Notes          string           `json:"notes"`
+	Speakers       []pretalxSpeaker `json:"speakers"`
+}
+
+type pretalxSpeaker struct {
+	Code      string `json:"code"`
+	Name      string `json:"name"`
+	Biography string `json:"biography"`
+	Avatar    string `json:"avatar"`
+}
+
+// pretalxText is a text which is either a plain string, or translations by locale.
+type pretalxText string
+
+func (t *pretalxText) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*t = pretalxText(s)
+		return nil
+	}
+	var translations map[string]string
+	if err := json.Unmarshal(data, &translations); err != nil {
+		return err
+	}
+	if en, ok := translations["en"]; ok {
+		*t = pretalxText(en)
+		return nil
+	}
+	locales := make([]string, 0, len(translations))
+	for locale := range translations {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	if len(locales) > 0 {
+		*t = pretalxText(translations[locales[0]])
+	}
+	return nil
+}
+
+func (f PretalxFile) Event(locate geo.Locator, opts ...ParseOption) (Event, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return Event{}, err
+	}
+
+	log.Printf("Parsing pretalx submissions from %s...", f.Path)
+	var submissions pretalxSubmissions
+	// submissions are either paginated or a plain list
+	if err := json.Unmarshal(data, &submissions); err != nil {
+		if err := json.Unmarshal(data, &submissions.Results); err != nil {
+			return Event{}, err
+		}
+	}
+	return ParseExport(submissions.toExport(f.EventName), locate, opts...)
+}
+
+// toExport maps the pretalx submissions to a Conference-Hall export.
+// Submission types are formats and tracks are categories.
+func (s pretalxSubmissions) toExport(eventName string) Export {
+	export := Export{Name: eventName}
+	formats := make(map[string]struct{})
+	categories := make(map[string]struct{})
+	speakers := make(map[string]struct{})
+	for _, submission := range s.Results {
+		talk := Talk{
+			ID:         submission.Code,
+			Title:      submission.Title,
+			State:      submission.State,
+			Abstract:   submission.Abstract,
+			Formats:    string(submission.SubmissionType),
+			Categories: string(submission.Track),
+			Language:   submission.ContentLocale,
+			Comments:   submission.Notes,
+		}
+		if submission.Description != "" {
+			talk.Abstract += "\n\n" + submission.Description
+		}
+		if _, ok := formats[talk.Formats]; !ok && talk.Formats != "" {
+			formats[talk.Formats] = struct{}{}
+			export.Formats = append(export.Formats, Format{ID: talk.Formats, Name: talk.Formats})
+		}
+		if _, ok := categories[talk.Categories]; !ok && talk.Categories != "" {
+			categories[talk.Categories] = struct{}{}
+			export.Categories = append(export.Categories, Category{ID: talk.Categories, Name: talk.Categories})
+		}
+		for _, speaker := range submission.Speakers {
+			talk.Speakers = append(talk.Speakers, speaker.Code)
+			if _, ok := speakers[speaker.Code]; ok {
+				continue
+			}
+			speakers[speaker.Code] = struct{}{}
+			export.Speakers = append(export.Speakers, Speaker{UID: speaker.Code, DisplayName: speaker.Name, Bio: speaker.Biography, PhotoURL: speaker.Avatar})
+		}
+		export.Warnings = append(export.Warnings, missingWarnings(talk)...)
+		export.Talks = append(export.Talks, talk)
+	}
+	return export
+}
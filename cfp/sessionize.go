@@ -0,0 +1,152 @@
+package cfp
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/bdxio/cfp-to-trello/common"
+	"github.com/bdxio/cfp-to-trello/geo"
+)
+
+// SessionizeFile is a Sessionize "All Data" JSON export file.
+// Formats, categories, levels and languages are read from the Sessionize categories having these titles, the event
+// name being missing from the export.
+type SessionizeFile struct {
+	Path      string
+	EventName string
+}
+
+type sessionizeExport struct {
+	Sessions   []sessionizeSession  `json:"sessions"`
+	Speakers   []sessionizeSpeaker  `json:"speakers"`
+	Categories []sessionizeCategory `json:"categories"`
+}
+
+type sessionizeSession struct {
+	ID            string   `json:"id"`
+	Title         string   `json:"title"`
+	Description   string   `json:"description"`
+	Status        string   `json:"status"`
+	Speakers      []string `json:"speakers"`
+	CategoryItems []int    `json:"categoryItems"`
+}
+
+type sessionizeSpeaker struct {
+	ID             string `json:"id"`
+	FullName       string `json:"fullName"`
+	TagLine        string `json:"tagLine"`
+	Bio            string `json:"bio"`
+	ProfilePicture string `json:"profilePicture"`
+	Links          []struct {
+		URL      string `json:"url"`
+		LinkType string `json:"linkType"`
+	} `json:"links"`
+}
+
+type sessionizeCategory struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	Items []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"items"`
+}
+
+// sessionizeCategoryTitles maps the titles of Sessionize categories to the proposal information they hold.
+var sessionizeCategoryTitles = map[string]string{
+	"session format": "format",
+	"format":         "format",
+	"track":          "category",
+	"category":       "category",
+	"topic":          "category",
+	"level":          "level",
+	"language":       "language",
+}
+
+var sessionizeStates = map[string]string{
+	"accepted": StateAccepted,
+	"declined": StateRejected,
+}
+
+func (f SessionizeFile) Event(locate geo.Locator, opts ...ParseOption) (Event, error) {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return Event{}, err
+	}
+	defer file.Close()
+
+	log.Printf("Parsing Sessionize export from %s...", f.Path)
+	var export sessionizeExport
+	if err := common.UnmarshalBody(file, &export); err != nil {
+		return Event{}, err
+	}
+	return ParseExport(export.toExport(f.EventName), locate, opts...)
+}
+
+// toExport maps the Sessionize export to a Conference-Hall one.
+func (e sessionizeExport) toExport(eventName string) Export {
+	export := Export{Name: eventName}
+	// kinds and names of the category items, by ID
+	kinds := make(map[int]string)
+	names := make(map[int]string)
+	for _, category := range e.Categories {
+		kind, ok := sessionizeCategoryTitles[strings.ToLower(category.Title)]
+		if !ok {
+			continue
+		}
+		for _, item := range category.Items {
+			kinds[item.ID] = kind
+			names[item.ID] = item.Name
+			switch kind {
+			case "format":
+				export.Formats = append(export.Formats, Format{ID: item.Name, Name: item.Name})
+			case "category":
+				export.Categories = append(export.Categories, Category{ID: item.Name, Name: item.Name})
+			}
+		}
+	}
+
+	for _, session := range e.Sessions {
+		state, ok := sessionizeStates[strings.ToLower(session.Status)]
+		if !ok {
+			state = StateSubmitted
+		}
+		talk := Talk{
+			ID:       session.ID,
+			Title:    session.Title,
+			State:    state,
+			Abstract: session.Description,
+			Speakers: session.Speakers,
+		}
+		for _, id := range session.CategoryItems {
+			switch kinds[id] {
+			case "format":
+				talk.Formats = names[id]
+			case "category":
+				talk.Categories = names[id]
+			case "level":
+				talk.Level = strings.ToLower(names[id])
+			case "language":
+				talk.Language = names[id]
+			}
+		}
+		export.Warnings = append(export.Warnings, missingWarnings(talk)...)
+		export.Talks = append(export.Talks, talk)
+	}
+
+	for _, speaker := range e.Speakers {
+		// Sessionize has no company, it is usually found in the tag line
+		s := Speaker{UID: speaker.ID, DisplayName: speaker.FullName, Company: speaker.TagLine, Bio: speaker.Bio, PhotoURL: speaker.ProfilePicture}
+		for _, link := range speaker.Links {
+			switch link.LinkType {
+			case "Twitter":
+				s.Twitter = link.URL
+			case "GitHub":
+				s.GitHub = link.URL
+			}
+		}
+		export.Speakers = append(export.Speakers, s)
+	}
+	return export
+}
@@ -0,0 +1,57 @@
+package cfp
+
+import (
+	"log"
+	"os"
+
+	"github.com/bdxio/cfp-to-trello/common"
+	"github.com/bdxio/cfp-to-trello/geo"
+)
+
+// Source produces the event of a CFP, whatever the platform hosting it.
+type Source interface {
+	Event(locate geo.Locator, opts ...ParseOption) (Event, error)
+}
+
+// ConferenceHallFile is a Conference-Hall JSON export file, following the schema of the API version, v1 by default.
+type ConferenceHallFile struct {
+	Path    string
+	Version APIVersion
+}
+
+// Export reads the export file, mapped to the schema of the first Conference-Hall API.
+func (f ConferenceHallFile) Export() (Export, error) {
+	if f.Version != APIv2 {
+		return ReadExport(f.Path)
+	}
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return Export{}, err
+	}
+	defer file.Close()
+
+	var export exportV2
+	if err := common.UnmarshalBody(file, &export); err != nil {
+		return Export{}, err
+	}
+	return export.toExport(), nil
+}
+
+func (f ConferenceHallFile) Event(locate geo.Locator, opts ...ParseOption) (Event, error) {
+	log.Printf("Parsing CFP export from %s...", f.Path)
+	export, err := f.Export()
+	if err != nil {
+		return Event{}, err
+	}
+	return ParseExport(export, locate, opts...)
+}
+
+// Event fetches the export of the event from Conference-Hall and parses it.
+func (c ConferenceHallClient) Event(locate geo.Locator, opts ...ParseOption) (Event, error) {
+	log.Printf("Fetching CFP export of event %s from Conference-Hall...", c.eventID)
+	export, err := c.GetExport()
+	if err != nil {
+		return Event{}, err
+	}
+	return ParseExport(export, locate, opts...)
+}
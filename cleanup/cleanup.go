@@ -0,0 +1,86 @@
+package cleanup
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/bdxio/cfp-to-trello/cfp"
+	"github.com/bdxio/cfp-to-trello/layout"
+	"github.com/bdxio/cfp-to-trello/trello"
+)
+
+// Confirm asks for the confirmation of an action.
+type Confirm func(prompt string) bool
+
+type Option func(c *cleaner)
+
+// WithLayout sets the layout of the deliberation boards.
+func WithLayout(l layout.Layout) Option {
+	return func(c *cleaner) {
+		c.layout = l
+	}
+}
+
+// WithDelete deletes the boards permanently instead of closing them.
+func WithDelete(del bool) Option {
+	return func(c *cleaner) {
+		c.delete = del
+	}
+}
+
+type cleaner struct {
+	layout layout.Layout
+	delete bool
+}
+
+// Cleanup closes, or deletes, the deliberation boards of an event once confirmed.
+func Cleanup(orgName string, event cfp.Event, client trello.Client, confirm Confirm, opts ...Option) error {
+	c := cleaner{layout: layout.Default()}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	organization, err := client.GetOrganization(orgName)
+	if err != nil {
+		return err
+	}
+	boards, err := client.GetBoards(organization, trello.PermissionLevelOrg)
+	if err != nil {
+		return err
+	}
+	if c.delete {
+		boards = c.layout.FilterAllBoards(boards, event.Name, event.Formats)
+	} else {
+		boards = c.layout.FilterBoards(boards, event.Name, event.Formats)
+	}
+	if len(boards) == 0 {
+		return errors.New("no board for CFP found in Trello")
+	}
+
+	action := "close"
+	if c.delete {
+		action = "permanently delete"
+	}
+	for _, board := range boards {
+		log.Printf("Board %s\n", board.Name)
+	}
+	if !confirm(fmt.Sprintf("Do you want to %s these %d boards?", action, len(boards))) {
+		log.Println("Cleanup cancelled")
+		return nil
+	}
+
+	for _, board := range boards {
+		if c.delete {
+			log.Printf("Deleting board %s...\n", board.Name)
+			err = client.DeleteBoard(board)
+		} else {
+			log.Printf("Closing board %s...\n", board.Name)
+			err = client.CloseBoard(board)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package cleanup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdxio/cfp-to-trello/cfp"
+	"github.com/bdxio/cfp-to-trello/trello"
+)
+
+var event = cfp.Event{Name: "Awesome Conference 2042", Formats: []string{"Format 1", "Format 2"}}
+
+func setupTrello(t *testing.T) trello.FakeClient {
+	org := trello.Organization{}
+	client := trello.NewFakeClient()
+	board, err := client.CreateBoard(org, "Délibération Awesome Conference 2042 - Format 1", trello.PermissionLevelOrg)
+	require.NoError(t, err)
+	list, err := client.CreateList(trello.ListSelection, board)
+	require.NoError(t, err)
+	_, err = client.CreateCard("A beginner talk in category 1", "", list, nil)
+	require.NoError(t, err)
+	_, err = client.CreateBoard(org, "Délibération Awesome Conference 2042 - Format 2", trello.PermissionLevelOrg)
+	require.NoError(t, err)
+	_, err = client.CreateBoard(org, "Délibération Awesome Conference 2041 - Format 1", trello.PermissionLevelOrg)
+	require.NoError(t, err)
+	return client
+}
+
+func TestCleanup_Close(t *testing.T) {
+	client := setupTrello(t)
+	var prompt string
+
+	err := Cleanup("test", event, client, func(p string) bool {
+		prompt = p
+		return true
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Do you want to close these 2 boards?", prompt)
+	assert.Equal(
+		t,
+		map[string]bool{
+			"Délibération Awesome Conference 2042 - Format 1": true,
+			"Délibération Awesome Conference 2042 - Format 2": true,
+		},
+		client.ClosedBoards,
+	)
+	assert.Len(t, client.Boards, 3)
+}
+
+func TestCleanup_Delete(t *testing.T) {
+	client := setupTrello(t)
+	require.NoError(t, client.CloseBoard(trello.Board{ID: "Délibération Awesome Conference 2042 - Format 2"}))
+
+	err := Cleanup("test", event, client, func(string) bool { return true }, WithDelete(true))
+
+	require.NoError(t, err)
+	assert.Len(t, client.Boards, 1)
+	assert.Contains(t, client.Boards, "Délibération Awesome Conference 2041 - Format 1")
+	assert.Empty(t, client.Lists)
+	assert.Empty(t, client.Cards)
+}
+
+func TestCleanup_Cancelled(t *testing.T) {
+	client := setupTrello(t)
+
+	err := Cleanup("test", event, client, func(string) bool { return false }, WithDelete(true))
+
+	require.NoError(t, err)
+	assert.Len(t, client.Boards, 3)
+	assert.Empty(t, client.ClosedBoards)
+}
+
+func TestCleanup_NoBoard(t *testing.T) {
+	client := trello.NewFakeClient()
+
+	err := Cleanup("test", event, client, func(string) bool { return true })
+
+	assert.EqualError(t, err, "no board for CFP found in Trello")
+}
@@ -0,0 +1,224 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"text/tabwriter"
+
+	"github.com/bdxio/cfp-to-trello/cfp"
+)
+
+// DefaultRatingThreshold is the smallest rating move reported by default.
+const DefaultRatingThreshold = 0.5
+
+type Kind string
+
+const (
+	KindNew            Kind = "new"
+	KindWithdrawn      Kind = "withdrawn" // talk missing from the newer export
+	KindState          Kind = "state"
+	KindTitle          Kind = "title"
+	KindAbstract       Kind = "abstract"
+	KindRating         Kind = "rating"
+	KindSpeakerAdded   Kind = "speaker-added"
+	KindSpeakerRemoved Kind = "speaker-removed"
+	KindMessage        Kind = "message"
+)
+
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+// Change is a difference found on a talk between two exports.
+type Change struct {
+	TalkID string `json:"talkId"`
+	Title  string `json:"title"`
+	Kind   Kind   `json:"kind"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+func (c Change) details() string {
+	switch c.Kind {
+	case KindNew:
+		return c.After
+	case KindWithdrawn:
+		return "was " + c.Before
+	case KindAbstract:
+		return "abstract changed"
+	case KindSpeakerAdded, KindMessage:
+		return c.After
+	case KindSpeakerRemoved:
+		return c.Before
+	default:
+		return c.Before + " → " + c.After
+	}
+}
+
+// Diff holds the changes between two exports, talk by talk in the order of the newer export, withdrawn talks last.
+type Diff struct {
+	Changes []Change
+}
+
+type Option func(d *differ)
+
+// WithRatingThreshold sets the smallest rating move reported.
+func WithRatingThreshold(threshold float64) Option {
+	return func(d *differ) {
+		d.ratingThreshold = threshold
+	}
+}
+
+type differ struct {
+	ratingThreshold float64
+	speakers        map[string]string // speaker names by UID, from both exports
+	changes         []Change
+}
+
+// Compare returns the changes from the before export to the after one.
+func Compare(before, after cfp.Export, opts ...Option) Diff {
+	d := differ{ratingThreshold: DefaultRatingThreshold, speakers: make(map[string]string)}
+	for _, opt := range opts {
+		opt(&d)
+	}
+	for _, export := range []cfp.Export{before, after} {
+		for _, speaker := range export.Speakers {
+			name := speaker.DisplayName
+			if name == "" {
+				name = speaker.Email
+			}
+			d.speakers[speaker.UID] = name
+		}
+	}
+
+	previous := make(map[string]cfp.Talk, len(before.Talks))
+	for _, talk := range before.Talks {
+		previous[talk.ID] = talk
+	}
+	current := make(map[string]bool, len(after.Talks))
+	for _, talk := range after.Talks {
+		current[talk.ID] = true
+		old, ok := previous[talk.ID]
+		if !ok {
+			d.add(talk, KindNew, "", talk.State)
+			continue
+		}
+		d.compareTalks(old, talk)
+	}
+	for _, talk := range before.Talks {
+		if !current[talk.ID] {
+			d.add(talk, KindWithdrawn, talk.State, "")
+		}
+	}
+	return Diff{Changes: d.changes}
+}
+
+func (d *differ) add(talk cfp.Talk, kind Kind, before, after string) {
+	d.changes = append(d.changes, Change{TalkID: talk.ID, Title: talk.Title, Kind: kind, Before: before, After: after})
+}
+
+func (d *differ) compareTalks(old, talk cfp.Talk) {
+	if old.State != talk.State {
+		d.add(talk, KindState, old.State, talk.State)
+	}
+	if old.Title != talk.Title {
+		d.add(talk, KindTitle, old.Title, talk.Title)
+	}
+	if old.Abstract != talk.Abstract {
+		d.add(talk, KindAbstract, old.Abstract, talk.Abstract)
+	}
+	if delta := math.Abs(talk.Rating - old.Rating); delta > 0 && delta >= d.ratingThreshold {
+		d.add(talk, KindRating, fmt.Sprintf("%.2f", old.Rating), fmt.Sprintf("%.2f", talk.Rating))
+	}
+
+	for _, uid := range missing(old.Speakers, talk.Speakers) {
+		d.add(talk, KindSpeakerAdded, "", d.speakers[uid])
+	}
+	for _, uid := range missing(talk.Speakers, old.Speakers) {
+		d.add(talk, KindSpeakerRemoved, d.speakers[uid], "")
+	}
+
+	messages := make(map[cfp.OrganizerThread]bool, len(old.OrganizersThread))
+	for _, thread := range old.OrganizersThread {
+		messages[thread] = true
+	}
+	for _, thread := range talk.OrganizersThread {
+		if !messages[thread] {
+			d.add(talk, KindMessage, "", fmt.Sprintf("%s: %s", thread.DisplayName, thread.Message))
+		}
+	}
+}
+
+// missing returns the values of b missing from a.
+func missing(a, b []string) []string {
+	found := make(map[string]bool, len(a))
+	for _, v := range a {
+		found[v] = true
+	}
+	m := make([]string, 0)
+	for _, v := range b {
+		if !found[v] {
+			m = append(m, v)
+		}
+	}
+	return m
+}
+
+// Count returns the number of changes of the given kind.
+func (d Diff) Count(kind Kind) int {
+	n := 0
+	for _, c := range d.Changes {
+		if c.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// Write writes the changes as a text table or as JSON.
+func (d Diff) Write(w io.Writer, format Format) error {
+	switch format {
+	case FormatText:
+		return d.writeText(w)
+	case FormatJSON:
+		changes := d.Changes
+		if changes == nil {
+			changes = []Change{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(changes)
+	default:
+		return fmt.Errorf("unknown diff format %s", format)
+	}
+}
+
+func (d Diff) writeText(w io.Writer) error {
+	if len(d.Changes) == 0 {
+		_, err := fmt.Fprintln(w, "No change")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TALK\tCHANGE\tDETAILS")
+	for _, c := range d.Changes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Title, c.Kind, c.details())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(
+		w,
+		"\n%d new, %d withdrawn, %d state changes, %d rating moves, %d new messages\n",
+		d.Count(KindNew),
+		d.Count(KindWithdrawn),
+		d.Count(KindState),
+		d.Count(KindRating),
+		d.Count(KindMessage),
+	)
+	return err
+}
@@ -0,0 +1,109 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdxio/cfp-to-trello/cfp"
+)
+
+func TestCompare(t *testing.T) {
+	before := cfp.Export{
+		Speakers: []cfp.Speaker{{UID: "s1", DisplayName: "Leala Simard"}, {UID: "s2", DisplayName: "Dev from UK"}},
+		Talks: []cfp.Talk{
+			{ID: "1", Title: "A talk", State: "submitted", Abstract: "An abstract", Rating: 3, Speakers: []string{"s1"}},
+			{ID: "2", Title: "Another talk", State: "submitted", Rating: 2, Speakers: []string{"s1", "s2"}},
+			{ID: "3", Title: "A withdrawn talk", State: "submitted"},
+		},
+	}
+	after := cfp.Export{
+		Speakers: []cfp.Speaker{{UID: "s1", DisplayName: "Leala Simard"}, {UID: "s3", Email: "kari@example.com"}},
+		Talks: []cfp.Talk{
+			{
+				ID:       "1",
+				Title:    "A better talk",
+				State:    "accepted",
+				Abstract: "A longer abstract",
+				Rating:   3.6,
+				Speakers: []string{"s1", "s3"},
+				OrganizersThread: []cfp.OrganizerThread{
+					{DisplayName: "Orga One", Message: "Great talk", Date: cfp.Date{Seconds: 1659606240}},
+				},
+			},
+			{ID: "2", Title: "Another talk", State: "submitted", Rating: 2.4, Speakers: []string{"s1"}},
+			{ID: "4", Title: "A new talk", State: "submitted"},
+		},
+	}
+
+	d := Compare(before, after)
+
+	assert.Equal(
+		t,
+		[]Change{
+			{TalkID: "1", Title: "A better talk", Kind: KindState, Before: "submitted", After: "accepted"},
+			{TalkID: "1", Title: "A better talk", Kind: KindTitle, Before: "A talk", After: "A better talk"},
+			{TalkID: "1", Title: "A better talk", Kind: KindAbstract, Before: "An abstract", After: "A longer abstract"},
+			{TalkID: "1", Title: "A better talk", Kind: KindRating, Before: "3.00", After: "3.60"},
+			{TalkID: "1", Title: "A better talk", Kind: KindSpeakerAdded, After: "kari@example.com"},
+			{TalkID: "1", Title: "A better talk", Kind: KindMessage, After: "Orga One: Great talk"},
+			{TalkID: "2", Title: "Another talk", Kind: KindSpeakerRemoved, Before: "Dev from UK"},
+			{TalkID: "4", Title: "A new talk", Kind: KindNew, After: "submitted"},
+			{TalkID: "3", Title: "A withdrawn talk", Kind: KindWithdrawn, Before: "submitted"},
+		},
+		d.Changes,
+	)
+
+	d = Compare(before, after, WithRatingThreshold(0.3))
+	assert.Equal(t, 2, d.Count(KindRating))
+
+	// unchanged ratings are never reported
+	d = Compare(before, before, WithRatingThreshold(0))
+	assert.Empty(t, d.Changes)
+	d = Compare(before, after, WithRatingThreshold(0))
+	assert.Equal(t, 2, d.Count(KindRating))
+}
+
+func TestCompare_SameExport(t *testing.T) {
+	export, err := cfp.ReadExport("../cfp/testdata/export.json")
+	require.NoError(t, err)
+
+	d := Compare(export, export)
+
+	assert.Empty(t, d.Changes)
+	var out bytes.Buffer
+	require.NoError(t, d.Write(&out, FormatText))
+	assert.Equal(t, "No change\n", out.String())
+	out.Reset()
+	require.NoError(t, d.Write(&out, FormatJSON))
+	assert.Equal(t, "[]\n", out.String())
+}
+
+func TestDiff_Write(t *testing.T) {
+	d := Diff{Changes: []Change{
+		{TalkID: "1", Title: "A talk", Kind: KindState, Before: "submitted", After: "accepted"},
+		{TalkID: "2", Title: "Another talk", Kind: KindWithdrawn, Before: "submitted"},
+	}}
+
+	var text bytes.Buffer
+	require.NoError(t, d.Write(&text, FormatText))
+	assert.Equal(
+		t,
+		"TALK          CHANGE     DETAILS\n"+
+			"A talk        state      submitted → accepted\n"+
+			"Another talk  withdrawn  was submitted\n"+
+			"\n0 new, 1 withdrawn, 1 state changes, 0 rating moves, 0 new messages\n",
+		text.String(),
+	)
+
+	var data bytes.Buffer
+	require.NoError(t, d.Write(&data, FormatJSON))
+	var changes []map[string]string
+	require.NoError(t, json.Unmarshal(data.Bytes(), &changes))
+	assert.Equal(t, map[string]string{"talkId": "2", "title": "Another talk", "kind": "withdrawn", "before": "submitted"}, changes[1])
+
+	assert.EqualError(t, d.Write(&data, "xml"), "unknown diff format xml")
+}
@@ -0,0 +1,168 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/bdxio/cfp-to-trello/layout"
+	"github.com/bdxio/cfp-to-trello/trello"
+)
+
+// SpeakerMapping records the speakers of the cards imported anonymously, so that they can be revealed once the blind
+// deliberation is over. It must be kept away from reviewers.
+type SpeakerMapping struct {
+	Cards map[string]HiddenSpeakers `json:"cards"` // indexed by card ID
+
+	path string // where the mapping is saved, kept in memory only if empty
+	mu   sync.Mutex
+}
+
+// HiddenSpeakers are the speakers left out of a card.
+type HiddenSpeakers struct {
+	BoardID    string `json:"boardId"`
+	ProposalID string `json:"proposalId"`
+	Speakers   string `json:"speakers"`
+}
+
+func NewSpeakerMapping() *SpeakerMapping {
+	return &SpeakerMapping{Cards: make(map[string]HiddenSpeakers)}
+}
+
+// LoadSpeakerMapping reads a speaker mapping from a JSON file.
+func LoadSpeakerMapping(path string) (*SpeakerMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := NewSpeakerMapping()
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid speaker mapping %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// OpenSpeakerMapping opens the mapping saved at path, or starts a new one if there is none yet, so that the speakers
+// hidden by previous imports, e.g. of other formats, are kept. The mapping is saved as soon as a card is recorded.
+func OpenSpeakerMapping(path string) (*SpeakerMapping, error) {
+	m, err := LoadSpeakerMapping(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		m = NewSpeakerMapping()
+	} else if err != nil {
+		return nil, err
+	}
+	m.path = path
+	return m, nil
+}
+
+func (m *SpeakerMapping) record(cardID string, speakers HiddenSpeakers) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Cards[cardID] = speakers
+	if m.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	// only readable by its owner, to be kept away from reviewers, and renamed so that an interrupted import can't
+	// leave a truncated mapping
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// WithAnonymous leaves the speakers out of the cards, recording them in the mapping instead.
+// The private messages are dropped if dropMessages is true, otherwise they are scrubbed like the other texts.
+func WithAnonymous(mapping *SpeakerMapping, dropMessages bool) Option {
+	return func(t *Trello) {
+		t.speakers = mapping
+		t.dropMessages = dropMessages
+	}
+}
+
+// Reveal puts back the speakers of the cards imported anonymously, as labels and in the speakers custom field.
+// Speaker labels get the color of the speakers marker of the layout if any.
+func Reveal(mapping *SpeakerMapping, client trello.Client, opts ...Option) error {
+	t := Trello{client: client, layout: layout.Default()}
+	for _, opt := range opts {
+		opt(&t)
+	}
+	color := trello.ColorPurple
+	for _, l := range t.layout.Labels {
+		if l.Marker == layout.MarkerSpeakers {
+			color = l.Color
+		}
+	}
+
+	cardIDs := make([]string, 0, len(mapping.Cards))
+	for id := range mapping.Cards {
+		cardIDs = append(cardIDs, id)
+	}
+	sort.Strings(cardIDs)
+	log.Printf("Revealing the speakers of %d cards...", len(cardIDs))
+	boardCards := make(map[string]map[string]trello.Card)
+	for _, id := range cardIDs {
+		hidden := mapping.Cards[id]
+		board := trello.Board{ID: hidden.BoardID}
+		cards, ok := boardCards[board.ID]
+		if !ok {
+			var err error
+			cards, err = t.loadCards(board)
+			if err != nil {
+				return err
+			}
+			boardCards[board.ID] = cards
+		}
+		card, ok := cards[id]
+		if !ok {
+			log.Printf("Card of proposal %s not found, ignoring it", hidden.ProposalID)
+			continue
+		}
+		label, err := client.CreateLabel(hidden.Speakers, board, color)
+		if err != nil {
+			return err
+		}
+		// Trello refuses to add a label twice, e.g. when revealing again
+		if !card.HasLabel(label) {
+			if err := client.AddLabel(card, label); err != nil {
+				return fmt.Errorf("error while revealing speakers of proposal %s: %w", hidden.ProposalID, err)
+			}
+		}
+		field, err := client.CreateCustomField(speakersField, board, trello.CustomFieldText)
+		if err != nil {
+			return err
+		}
+		if err := client.SetCustomFieldValue(card, field, hidden.Speakers); err != nil {
+			return fmt.Errorf("error while revealing speakers of proposal %s: %w", hidden.ProposalID, err)
+		}
+	}
+	return nil
+}
+
+// loadCards fetches the cards of a board, indexed by ID.
+func (t Trello) loadCards(board trello.Board) (map[string]trello.Card, error) {
+	cards := make(map[string]trello.Card)
+	lists, err := t.client.GetLists(board)
+	if err != nil {
+		return nil, err
+	}
+	for _, list := range lists {
+		listCards, err := t.client.GetCards(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, card := range listCards {
+			cards[card.ID] = card
+		}
+	}
+	return cards, nil
+}
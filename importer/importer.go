@@ -4,30 +4,107 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"math"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/sync/errgroup"
 
 	"github.com/bdxio/cfp-to-trello/cfp"
-	"github.com/bdxio/cfp-to-trello/geo"
+	"github.com/bdxio/cfp-to-trello/layout"
 	"github.com/bdxio/cfp-to-trello/trello"
 )
 
-func ImportCFP(orgName, eventID, jsonPath string, locate geo.Locator, client trello.Client) error {
-	event, err := cfp.Parse(jsonPath, locate)
-	if err != nil {
-		return err
+type Option func(t *Trello)
+
+// WithSync reuses the deliberation boards already existing in Trello, updating their cards in place.
+func WithSync(sync bool) Option {
+	return func(t *Trello) {
+		t.sync = sync
+	}
+}
+
+func ImportCFP(orgName, eventID string, event cfp.Event, client trello.Client, opts ...Option) error {
+	t := Trello{eventID: eventID, client: client, event: event, tiering: DefaultTiering, formatTierings: make(map[string]Tiering), layout: layout.Default()}
+	for _, opt := range opts {
+		opt(&t)
 	}
-	t := Trello{eventID: eventID, client: client, event: event}
 	return t.importCFP(orgName)
 }
 
+// WithTiering sets the tiering used for the formats without a specific tiering.
+func WithTiering(tiering Tiering) Option {
+	return func(t *Trello) {
+		t.tiering = tiering
+	}
+}
+
+// WithFormatTiering sets the tiering used for the given format.
+func WithFormatTiering(format string, tiering Tiering) Option {
+	return func(t *Trello) {
+		t.formatTierings[format] = tiering
+	}
+}
+
+// WithLayout sets the layout of the created boards.
+func WithLayout(l layout.Layout) Option {
+	return func(t *Trello) {
+		t.layout = l
+	}
+}
+
+// WithJournal records the created Trello elements in the journal, and skips the ones it already holds.
+func WithJournal(journal *Journal) Option {
+	return func(t *Trello) {
+		t.journal = journal
+	}
+}
+
 type Trello struct {
-	eventID string
-	client  trello.Client
-	event   cfp.Event
+	eventID        string
+	client         trello.Client
+	event          cfp.Event
+	sync           bool
+	tiering        Tiering
+	formatTierings map[string]Tiering
+	layout         layout.Layout
+	journal        *Journal
+	speakers       *SpeakerMapping // speakers left out of the cards when importing anonymously
+	dropMessages   bool
+}
+
+// markers return the label name of each marker for a proposal.
+var markers = map[layout.Marker]func(p cfp.Proposal) string{
+	layout.MarkerCategory: func(p cfp.Proposal) string { return p.Category },
+	layout.MarkerRating:   func(p cfp.Proposal) string { return fmt.Sprintf("🏅 %1.1f", p.Rating) },
+	layout.MarkerVotes:    func(p cfp.Proposal) string { return fmt.Sprintf("%d ❤️ / %d ☠️", p.Loves, p.Hates) },
+	layout.MarkerSpeakers: func(p cfp.Proposal) string { return p.SpeakersLabel() },
+	layout.MarkerLevel:    func(p cfp.Proposal) string { return p.AudienceLevel },
+	layout.MarkerLanguage: func(p cfp.Proposal) string { return p.Language },
+}
+
+// deliberationBoard holds the lists and cards of a board.
+type deliberationBoard struct {
+	trello.Board
+	lists  map[string]trello.List
+	cards  map[string]trello.Card // indexed by proposal ID
+	fields []trello.CustomField   // in the same order as customFields
+	reused bool
+}
+
+const speakersField = "Speakers"
+
+// customFields are the proposal information stored in custom fields.
+var customFields = []struct {
+	name      string
+	fieldType trello.CustomFieldType
+	value     func(p cfp.Proposal) string
+}{
+	{"Rating", trello.CustomFieldNumber, func(p cfp.Proposal) string { return strconv.FormatFloat(p.Rating, 'f', 2, 64) }},
+	{"Loves", trello.CustomFieldNumber, func(p cfp.Proposal) string { return strconv.Itoa(p.Loves) }},
+	{"Hates", trello.CustomFieldNumber, func(p cfp.Proposal) string { return strconv.Itoa(p.Hates) }},
+	{speakersField, trello.CustomFieldText, func(p cfp.Proposal) string { return p.SpeakersLabel() }},
 }
 
 func (t Trello) importCFP(organizationName string) error {
@@ -42,53 +119,151 @@ func (t Trello) importCFP(organizationName string) error {
 		return err
 	}
 
+	var existingBoards []trello.Board
+	if t.sync {
+		existingBoards, err = t.client.GetBoards(organization, trello.PermissionLevelOrg)
+		if err != nil {
+			return err
+		}
+	}
+
 	log.Printf("Importing event %s in Trello organization %s...\n", t.event.Name, organizationName)
 	g, _ := errgroup.WithContext(context.Background())
 	for _, format := range t.event.Formats {
 		g.Go(func(f string) func() error {
 			return func() error {
-				return t.createBoard(organization, f)
+				return t.createBoard(organization, existingBoards, f)
 			}
 		}(format))
 	}
 	return g.Wait()
 }
 
-func (t Trello) createBoard(organization trello.Organization, format string) error {
+func (t Trello) createBoard(organization trello.Organization, existingBoards []trello.Board, format string) error {
 	proposals := t.event.GetProposals(format)
 	if len(proposals) == 0 {
 		return nil
 	}
 
-	// Create board
-	boardName := fmt.Sprintf("Délibération %s - %s", t.event.Name, format)
-	log.Printf("Creating board %s for %d proposals...\n", boardName, len(proposals))
-	board, err := t.client.CreateBoard(organization, boardName, trello.PermissionLevelOrg)
+	boardName := t.layout.BoardNameFor(t.event.Name, format)
+	board, err := t.getOrCreateBoard(organization, existingBoards, boardName, len(proposals))
 	if err != nil {
 		return err
 	}
 
-	// Create lists
-	for _, name := range []string{trello.ListSelection, "Désistements", "Backups Acceptés", "Backups"} {
-		_, err := t.client.CreateList(name, board)
+	for _, field := range customFields {
+		key := board.ID + "|" + field.name
+		if id, ok := t.journal.lookup(journalField, key); ok {
+			board.fields = append(board.fields, trello.CustomField{ID: id, Name: field.name, Type: field.fieldType})
+			continue
+		}
+		f, err := t.client.CreateCustomField(field.name, board.Board, field.fieldType)
 		if err != nil {
 			return err
 		}
+		if err := t.journal.record(journalField, key, f.ID); err != nil {
+			return err
+		}
+		board.fields = append(board.fields, f)
 	}
 
-	if err := t.createDeliberationLists(board, format); err != nil {
-		return err
+	// Create lists
+	for _, list := range t.layout.Lists {
+		if list.Role == layout.RoleTiers {
+			if err := t.createDeliberationLists(board, format); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := t.getOrCreateList(board, list.Name); err != nil {
+			return err
+		}
 	}
 
-	if _, err := t.client.CreateList("Refusés", board); err != nil {
-		return err
+	if board.reused {
+		log.Printf("Successfully synced board %s: %s", board.Name, board.URL)
+	} else {
+		log.Printf("Successfully created board %s: %s", board.Name, board.URL)
 	}
-
-	log.Printf("Successfully created board %s: %s", board.Name, board.URL)
 	return nil
 }
 
-func (t Trello) createDeliberationLists(board trello.Board, format string) error {
+func (t Trello) getOrCreateBoard(organization trello.Organization, existingBoards []trello.Board, name string, nbProposals int) (*deliberationBoard, error) {
+	for _, board := range existingBoards {
+		// closed boards may have the same name
+		if board.Name == name && !board.Closed {
+			log.Printf("Reusing board %s for %d proposals...\n", name, nbProposals)
+			return t.loadBoard(board)
+		}
+	}
+
+	if id, ok := t.journal.lookup(journalBoard, name); ok {
+		log.Printf("Resuming board %s for %d proposals...\n", name, nbProposals)
+		return &deliberationBoard{Board: trello.Board{ID: id, Name: name}, lists: make(map[string]trello.List), cards: make(map[string]trello.Card)}, nil
+	}
+
+	log.Printf("Creating board %s for %d proposals...\n", name, nbProposals)
+	board, err := t.client.CreateBoard(organization, name, trello.PermissionLevelOrg)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.journal.record(journalBoard, name, board.ID); err != nil {
+		return nil, err
+	}
+	return &deliberationBoard{Board: board, lists: make(map[string]trello.List), cards: make(map[string]trello.Card)}, nil
+}
+
+// loadBoard fetches the lists and cards of an existing board, cards being indexed by proposal ID.
+func (t Trello) loadBoard(board trello.Board) (*deliberationBoard, error) {
+	b := &deliberationBoard{Board: board, lists: make(map[string]trello.List), cards: make(map[string]trello.Card), reused: true}
+	lists, err := t.client.GetLists(board)
+	if err != nil {
+		return nil, err
+	}
+	for _, list := range lists {
+		b.lists[list.Name] = list
+		cards, err := t.client.GetCards(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, card := range cards {
+			id, ok := cfp.FindProposalID(card.Desc)
+			if !ok {
+				log.Printf("No proposal found for card %q, ignoring it", card.Name)
+				continue
+			}
+			b.cards[id] = card
+		}
+	}
+	return b, nil
+}
+
+func (t Trello) getOrCreateList(board *deliberationBoard, name string) (trello.List, error) {
+	if list, ok := board.lists[name]; ok {
+		return list, nil
+	}
+	key := board.ID + "|" + name
+	if id, ok := t.journal.lookup(journalList, key); ok {
+		list := trello.List{ID: id, Name: name}
+		board.lists[name] = list
+		return list, nil
+	}
+	list, err := t.client.CreateList(name, board.Board)
+	if err != nil {
+		return trello.List{}, err
+	}
+	if err := t.journal.record(journalList, key, list.ID); err != nil {
+		return trello.List{}, err
+	}
+	board.lists[name] = list
+	return list, nil
+}
+
+func (t Trello) createDeliberationLists(board *deliberationBoard, format string) error {
+	tiering, ok := t.formatTierings[format]
+	if !ok {
+		tiering = t.tiering
+	}
 	proposalsByCategory := t.event.GetProposalsByCategory(format)
 	lastTierProposals := make([]cfp.Proposal, 0)
 	for _, category := range t.event.Categories {
@@ -96,7 +271,7 @@ func (t Trello) createDeliberationLists(board trello.Board, format string) error
 		if len(proposals) == 0 {
 			continue
 		}
-		remainingProposals, err := t.createCategoryDeliberationLists(board, category, proposals)
+		remainingProposals, err := t.createCategoryDeliberationLists(board, category, proposals, tiering)
 		if err != nil {
 			return err
 		}
@@ -104,10 +279,14 @@ func (t Trello) createDeliberationLists(board trello.Board, format string) error
 			lastTierProposals = append(lastTierProposals, remainingProposals...)
 		}
 	}
-	return t.createDeliberationList(board, "T3", lastTierProposals)
+	if !tiering.MergeLastTier {
+		return nil
+	}
+	return t.createDeliberationList(board, fmt.Sprintf("T%d", tiering.Tiers()), lastTierProposals)
 }
 
-func (t Trello) createCategoryDeliberationLists(board trello.Board, category string, proposals []cfp.Proposal) ([]cfp.Proposal, error) {
+// createCategoryDeliberationLists creates the tier lists of a category, returning the proposals of a merged last tier.
+func (t Trello) createCategoryDeliberationLists(board *deliberationBoard, category string, proposals []cfp.Proposal, tiering Tiering) ([]cfp.Proposal, error) {
 	sort.Slice(proposals, func(i, j int) bool {
 		p1 := proposals[i]
 		p2 := proposals[j]
@@ -131,19 +310,21 @@ func (t Trello) createCategoryDeliberationLists(board trello.Board, category str
 		return false
 	})
 
-	size := int(math.Ceil(float64(len(proposals)) / 3))
-	if err := t.createDeliberationList(board, fmt.Sprintf("%s - T1", category), proposals[:size]); err != nil {
-		return nil, err
-	}
-	if err := t.createDeliberationList(board, fmt.Sprintf("%s - T2", category), proposals[size:size*2]); err != nil {
-		return nil, err
+	tiers := tiering.Split(proposals)
+	for i, tier := range tiers {
+		if i == len(tiers)-1 && tiering.MergeLastTier {
+			return tier, nil
+		}
+		if err := t.createDeliberationList(board, fmt.Sprintf("%s - T%d", category, i+1), tier); err != nil {
+			return nil, err
+		}
 	}
-	return proposals[size*2:], nil
+	return nil, nil
 }
 
-func (t Trello) createDeliberationList(board trello.Board, name string, proposals []cfp.Proposal) error {
+func (t Trello) createDeliberationList(board *deliberationBoard, name string, proposals []cfp.Proposal) error {
 	log.Printf("Creating deliberation list %s for %d proposals...\n", name, len(proposals))
-	list, err := t.client.CreateList(name, board)
+	list, err := t.getOrCreateList(board, name)
 	if err != nil {
 		return err
 	}
@@ -155,48 +336,161 @@ func (t Trello) createDeliberationList(board trello.Board, name string, proposal
 	return nil
 }
 
-func (t Trello) createProposalCard(board trello.Board, list trello.List, proposal cfp.Proposal) error {
-	log.Printf("Creating proposal card %s...\n", proposal.Title)
-	labels, err := t.createLabels(board, proposal,
-		func(p cfp.Proposal) (string, trello.Color) { return p.Category, trello.ColorGreen },
-		func(p cfp.Proposal) (string, trello.Color) {
-			return fmt.Sprintf("🏅 %1.1f", p.Rating), trello.ColorOrange
-		},
-		func(p cfp.Proposal) (string, trello.Color) {
-			return fmt.Sprintf("%d ❤️ / %d ☠️", p.Loves, p.Hates), trello.ColorRed
-		},
-		func(p cfp.Proposal) (string, trello.Color) { return p.Speakers, trello.ColorPurple },
-		func(p cfp.Proposal) (string, trello.Color) { return p.AudienceLevel, trello.ColorSky },
-		func(p cfp.Proposal) (string, trello.Color) { return p.Language, trello.ColorPink },
-	)
-	if err != nil {
+func (t Trello) createProposalCard(board *deliberationBoard, list trello.List, proposal cfp.Proposal) error {
+	hidden := HiddenSpeakers{BoardID: board.ID, ProposalID: proposal.ID, Speakers: proposal.SpeakersLabel()}
+	if t.speakers != nil {
+		proposal = proposal.Anonymize(t.dropMessages)
+	}
+	cardDescription := t.cardDescription(proposal)
+
+	// Cards already imported are left in their list, as organizers might have moved them.
+	if card, ok := board.cards[proposal.ID]; ok {
+		if t.speakers != nil {
+			if err := t.speakers.record(card.ID, hidden); err != nil {
+				return err
+			}
+		}
+		if card.Name == proposal.Title && card.Desc == cardDescription {
+			return nil
+		}
+		log.Printf("Updating proposal card %s...\n", proposal.Title)
+		_, err := t.client.UpdateCard(card, proposal.Title, cardDescription)
 		return err
 	}
 
-	proposalUrl := fmt.Sprintf("%s/organizer/event/%s/proposals/%s", cfp.URL, t.eventID, proposal.ID)
-	proposalLink := fmt.Sprintf("📜 [Proposal](%s)", proposalUrl)
-	cardDescription := fmt.Sprintf("%s\n\n---\n\n%s\n\n---\n\n%s", proposalLink, proposal.Abstract, proposal.PrivateMessage)
-	card, err := t.client.CreateCard(proposal.Title, cardDescription, list, labels)
+	card, err := t.getOrCreateCard(board, list, proposal, cardDescription)
 	if err != nil {
 		return err
 	}
+	if t.speakers != nil {
+		if err := t.speakers.record(card.ID, hidden); err != nil {
+			return err
+		}
+	}
+
+	for i, field := range board.fields {
+		key := card.ID + "|" + field.ID
+		if _, ok := t.journal.lookup(journalFieldValue, key); ok {
+			continue
+		}
+		value := customFields[i].value(proposal)
+		if value == "" {
+			// nothing to show, e.g. the speakers of an anonymous proposal
+			continue
+		}
+		if err := t.client.SetCustomFieldValue(card, field, value); err != nil {
+			return err
+		}
+		if err := t.journal.record(journalFieldValue, key, field.ID); err != nil {
+			return err
+		}
+	}
+
+	if len(proposal.Speakers) > 0 && proposal.Speakers[0].PhotoURL != "" {
+		if _, ok := t.journal.lookup(journalCover, card.ID); !ok {
+			// a missing photo must not stop the import
+			if err := t.client.SetCover(card, proposal.Speakers[0].PhotoURL); err != nil {
+				log.Printf("⚠️ Error while setting the cover of card %s: %v\n", card.Name, err)
+			} else if err := t.journal.record(journalCover, card.ID, card.ID); err != nil {
+				return err
+			}
+		}
+	}
 
-	for _, message := range proposal.OrganizerMessages {
+	for i, message := range proposal.OrganizerMessages {
+		key := fmt.Sprintf("%s|%d", card.ID, i)
+		if _, ok := t.journal.lookup(journalComment, key); ok {
+			continue
+		}
 		if err := t.client.CreateComment(message, card); err != nil {
 			return err
 		}
+		if err := t.journal.record(journalComment, key, card.ID); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (t Trello) createLabels(board trello.Board, p cfp.Proposal, markers ...func(p cfp.Proposal) (string, trello.Color)) ([]trello.Label, error) {
+// cardDescription returns the description of the card of a proposal.
+func (t Trello) cardDescription(proposal cfp.Proposal) string {
+	proposalUrl := fmt.Sprintf("%s/organizer/event/%s/proposals/%s", cfp.URL, t.eventID, proposal.ID)
+	proposalLink := fmt.Sprintf("📜 [Proposal](%s) · %s", proposalUrl, cfp.ProposalMarker(proposal.ID))
+	sections := []string{proposalLink, proposal.Abstract}
+	if proposal.PrivateMessage != "" {
+		sections = append(sections, proposal.PrivateMessage)
+	}
+	if proposal.References != "" {
+		sections = append(sections, "📚 **References**\n\n"+proposal.References)
+	}
+	for _, speaker := range proposal.Speakers {
+		sections = append(sections, speakerSection(speaker))
+	}
+	return strings.Join(sections, "\n\n---\n\n")
+}
+
+// speakerSection returns the details of a speaker.
+func speakerSection(speaker cfp.ProposalSpeaker) string {
+	paragraphs := []string{"🎤 **" + speaker.Label() + "**"}
+	links := make([]string, 0, 2)
+	if speaker.Twitter != "" {
+		links = append(links, fmt.Sprintf("🐦 [Twitter](%s)", speaker.Twitter))
+	}
+	if speaker.GitHub != "" {
+		links = append(links, fmt.Sprintf("🐙 [GitHub](%s)", speaker.GitHub))
+	}
+	if len(links) > 0 {
+		paragraphs = append(paragraphs, strings.Join(links, " · "))
+	}
+	if speaker.Bio != "" {
+		paragraphs = append(paragraphs, speaker.Bio)
+	}
+	if speaker.References != "" {
+		paragraphs = append(paragraphs, "_References_\n\n"+speaker.References)
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+func (t Trello) getOrCreateCard(board *deliberationBoard, list trello.List, proposal cfp.Proposal, desc string) (trello.Card, error) {
+	if id, ok := t.journal.lookup(journalCard, proposal.ID); ok {
+		return trello.Card{ID: id, Name: proposal.Title, Desc: desc}, nil
+	}
+
+	log.Printf("Creating proposal card %s...\n", proposal.Title)
+	labels, err := t.createLabels(board.Board, proposal)
+	if err != nil {
+		return trello.Card{}, err
+	}
+	card, err := t.client.CreateCard(proposal.Title, desc, list, labels)
+	if err != nil {
+		return trello.Card{}, err
+	}
+	if err := t.journal.record(journalCard, proposal.ID, card.ID); err != nil {
+		return trello.Card{}, err
+	}
+	return card, nil
+}
+
+func (t Trello) createLabels(board trello.Board, p cfp.Proposal) ([]trello.Label, error) {
 	labels := make([]trello.Label, 0)
-	for _, marker := range markers {
-		name, color := marker(p)
-		label, err := t.client.CreateLabel(name, board, color)
+	for _, l := range t.layout.Labels {
+		name := markers[l.Marker](p)
+		if name == "" {
+			// e.g. the speakers of an anonymous proposal
+			continue
+		}
+		key := board.ID + "|" + name
+		if id, ok := t.journal.lookup(journalLabel, key); ok {
+			labels = append(labels, trello.Label{ID: id})
+			continue
+		}
+		label, err := t.client.CreateLabel(name, board, l.Color)
 		if err != nil {
 			return nil, err
 		}
+		if err := t.journal.record(journalLabel, key, label.ID); err != nil {
+			return nil, err
+		}
 		labels = append(labels, label)
 	}
 	return labels, nil
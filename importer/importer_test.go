@@ -1,19 +1,26 @@
 package importer
 
 import (
+	"errors"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/bdxio/cfp-to-trello/cfp"
 	"github.com/bdxio/cfp-to-trello/geo"
+	"github.com/bdxio/cfp-to-trello/layout"
 	"github.com/bdxio/cfp-to-trello/trello"
 )
 
 func TestImportCFP(t *testing.T) {
 	client := trello.NewFakeClient()
+	event, err := cfp.Parse("../cfp/testdata/export.json", geo.FakeLocate)
+	require.NoError(t, err)
 
-	err := ImportCFP("test", "123", "../cfp/testdata/export.json", geo.FakeLocate, client)
+	err = ImportCFP("test", "123", event, client)
 	require.NoError(t, err)
 
 	// Check boards creation
@@ -34,41 +41,17 @@ func TestImportCFP(t *testing.T) {
 	assert.Contains(t, client.Lists, "Délibération Awesome Conference 2042 - Format 1-Refusés")
 
 	// Check labels creation
-	assert.Len(t, client.Labels, 26)
+	assert.Len(t, client.Labels, 8)
 	// Category labels
 	assert.Contains(t, client.Labels, "Category 1")
 	assert.Contains(t, client.Labels, "Category 2")
 	assert.Equal(t, trello.ColorGreen, client.Labels["Category 1"])
-	// Rating labels
-	assert.Contains(t, client.Labels, "🏅 2.7")
-	assert.Contains(t, client.Labels, "🏅 3.4")
-	assert.Contains(t, client.Labels, "🏅 4.1")
-	assert.Contains(t, client.Labels, "🏅 3.2")
-	assert.Contains(t, client.Labels, "🏅 1.5")
-	assert.Equal(t, trello.ColorOrange, client.Labels["🏅 2.7"])
-	// Loves/Hates labels
-	assert.Contains(t, client.Labels, "0 ❤️ / 0 ☠️")
-	assert.Contains(t, client.Labels, "1 ❤️ / 0 ☠️")
-	assert.Contains(t, client.Labels, "2 ❤️ / 1 ☠️")
-	assert.Contains(t, client.Labels, "2 ❤️ / 0 ☠️")
-	assert.Contains(t, client.Labels, "0 ❤️ / 3 ☠️")
-	assert.Equal(t, trello.ColorRed, client.Labels["0 ❤️ / 0 ☠️"])
-	// Speakers labels
-	assert.Contains(t, client.Labels, "Dev from UK - Loveston, UK (Big Bear Stores)")
-	assert.Contains(t, client.Labels, "Benjamin Salois - 🗺️ (Wealthy Ideas)")
-	assert.Contains(t, client.Labels, "Leala Simard - Carpentras, France (Gold Medal) / Kari Angélil - Muret, France / Anne Course - Lormont, France 🍷")
-	assert.Contains(t, client.Labels, "Leala Simard - Carpentras, France (Gold Medal) / Benjamin Salois - 🗺️ (Wealthy Ideas)")
-	assert.Contains(t, client.Labels, "Kari Angélil - Muret, France")
-	assert.Contains(t, client.Labels, "Leala Simard - Carpentras, France (Gold Medal) / Kari Angélil - Muret, France")
-	assert.Contains(t, client.Labels, "Leala Simard - Carpentras, France (Gold Medal)")
-	assert.Contains(t, client.Labels, "Leala Simard - Carpentras, France (Gold Medal) / Benjamin Salois - 🗺️ (Wealthy Ideas) / Dev from UK - Loveston, UK (Big Bear Stores)")
-	assert.Equal(t, trello.ColorPurple, client.Labels["Dev from UK - Loveston, UK (Big Bear Stores)"])
 	// Audience level labels
 	assert.Contains(t, client.Labels, "Débutant")
 	assert.Contains(t, client.Labels, "Intermédiaire")
 	assert.Contains(t, client.Labels, "Avancé")
 	assert.Equal(t, trello.ColorSky, client.Labels["Débutant"])
-	// Audience level labels
+	// Language labels
 	assert.Contains(t, client.Labels, "🇫🇷")
 	assert.Contains(t, client.Labels, "🇬🇧")
 	assert.Contains(t, client.Labels, "🇫🇷/🇬🇧")
@@ -87,14 +70,52 @@ func TestImportCFP(t *testing.T) {
 	assert.Equal(
 		t,
 		trello.Card{
-			ID:       "A beginner talk in category 1",
-			Name:     "A beginner talk in category 1",
-			Desc:     "📜 [Proposal](https://conference-hall.io/organizer/event/123/proposals/6grkSZ4ArcYr8BZfcw0o)\n\n---\n\nAn interesting abstract\n\n---\n\n",
-			IDLabels: []string{"Category 1", "🏅 2.7", "0 ❤️ / 0 ☠️", "Leala Simard - Carpentras, France (Gold Medal)", "Débutant", "🇫🇷"},
+			ID:   "A beginner talk in category 1",
+			Name: "A beginner talk in category 1",
+			Desc: "📜 [Proposal](https://conference-hall.io/organizer/event/123/proposals/6grkSZ4ArcYr8BZfcw0o) · 🆔 `6grkSZ4ArcYr8BZfcw0o`" +
+				"\n\n---\n\nAn interesting abstract" +
+				"\n\n---\n\n📚 **References**\n\nSome references" +
+				"\n\n---\n\n🎤 **Leala Simard - Carpentras, France (Gold Medal)**" +
+				"\n\n🐦 [Twitter](https://twitter.com/LealaSimard) · 🐙 [GitHub](https://github.com/lealasimard)" +
+				"\n\nDev 1" +
+				"\n\n_References_\n\nhttps://www.reference1.com\n\nhttps://www.reference2.com",
+			IDLabels: []string{"Category 1", "Débutant", "🇫🇷"},
 		},
 		client.Cards["A beginner talk in category 1"],
 	)
 
+	// Check covers
+	assert.Len(t, client.Covers, 8)
+	assert.Equal(t, "https://www.avatars.com/u/764359", client.Covers["A beginner talk in category 1"])
+
+	// Check custom fields
+	assert.Equal(
+		t,
+		map[string]trello.CustomFieldType{
+			"Rating":   trello.CustomFieldNumber,
+			"Loves":    trello.CustomFieldNumber,
+			"Hates":    trello.CustomFieldNumber,
+			"Speakers": trello.CustomFieldText,
+		},
+		client.CustomFields,
+	)
+	assert.Len(t, client.CustomFieldValues, 8)
+	assert.Equal(
+		t,
+		map[string]string{
+			"Rating":   "3.40",
+			"Loves":    "1",
+			"Hates":    "0",
+			"Speakers": "Benjamin Salois - 🗺️ (Wealthy Ideas)",
+		},
+		client.CustomFieldValues["An intermediate talk in category 1"],
+	)
+	assert.Equal(
+		t,
+		"Leala Simard - Carpentras, France (Gold Medal) / Kari Angélil - Muret, France / Anne Course - Lormont, France 🍷",
+		client.CustomFieldValues["Another talk in category 2"]["Speakers"],
+	)
+
 	// Check cards in lists
 	assert.Len(t, client.Lists["Délibération Awesome Conference 2042 - Format 1-Category 1 - T1"], 2)
 	assert.Equal(t, "An advanced talk in category 1", client.Lists["Délibération Awesome Conference 2042 - Format 1-Category 1 - T1"][0].Name)
@@ -119,3 +140,336 @@ func TestImportCFP(t *testing.T) {
 		client.Comments["Another beginner talk in category 1"],
 	)
 }
+
+func TestImportCFP_Sync(t *testing.T) {
+	client := trello.NewFakeClient()
+	event, err := cfp.Parse("../cfp/testdata/export.json", geo.FakeLocate)
+	require.NoError(t, err)
+	err = ImportCFP("test", "123", event, client)
+	require.NoError(t, err)
+
+	// An organizer moves a card to the selection and fixes a typo in another one
+	boardName := "Délibération Awesome Conference 2042 - Format 1"
+	t1 := client.Lists[boardName+"-Category 1 - T1"]
+	selected := t1[0]
+	client.Lists[boardName+"-Category 1 - T1"] = t1[1:]
+	client.Lists[boardName+"-Sélection"] = append(client.Lists[boardName+"-Sélection"], selected)
+	_, err = client.UpdateCard(client.Cards["A talk in category 2"], "A talk in category 2 (typo)", "")
+	require.NoError(t, err)
+	// and deletes another one
+	delete(client.Cards, "Still another talk in category 2")
+	client.Lists[boardName+"-T3"] = client.Lists[boardName+"-T3"][:1]
+
+	err = ImportCFP("test", "123", event, client, WithSync(true))
+	require.NoError(t, err)
+
+	assert.Len(t, client.Boards, 1)
+	assert.Len(t, client.Lists, 10)
+	assert.Len(t, client.Cards, 8)
+	// Moved card is left where it is
+	assert.Len(t, client.Lists[boardName+"-Sélection"], 1)
+	assert.Equal(t, selected.ID, client.Lists[boardName+"-Sélection"][0].ID)
+	assert.Len(t, client.Lists[boardName+"-Category 1 - T1"], 1)
+	// Updated card is restored from the CFP
+	card := client.Cards["A talk in category 2"]
+	assert.Equal(t, "A talk in category 2", card.Name)
+	assert.True(t, strings.HasPrefix(card.Desc, "📜 [Proposal](https://conference-hall.io/organizer/event/123/proposals/tzdLHxKDtVUXcJLd66TN) · 🆔 `tzdLHxKDtVUXcJLd66TN`\n\n---\n\nAn interesting abstract\n\n---\n\nTwo speakers, one without address"))
+	// Deleted card is created again
+	assert.Len(t, client.Lists[boardName+"-T3"], 2)
+	assert.Contains(t, client.Cards, "Still another talk in category 2")
+	// Comments are not duplicated
+	assert.Len(t, client.Comments["Another beginner talk in category 1"], 2)
+}
+
+// closedBoardClient lists a closed board named as the board of an event, as left by a previous import.
+type closedBoardClient struct {
+	trello.FakeClient
+	name string
+}
+
+func (c closedBoardClient) GetBoards(organization trello.Organization, permLvl trello.PermissionLevel) ([]trello.Board, error) {
+	boards, err := c.FakeClient.GetBoards(organization, permLvl)
+	closed := trello.Board{ID: "closed", Name: c.name, Closed: true}
+	return append([]trello.Board{closed}, boards...), err
+}
+
+func TestImportCFP_SyncClosedDuplicate(t *testing.T) {
+	boardName := "Délibération Awesome Conference 2042 - Format 1"
+	client := closedBoardClient{FakeClient: trello.NewFakeClient(), name: boardName}
+	event, err := cfp.Parse("../cfp/testdata/export.json", geo.FakeLocate)
+	require.NoError(t, err)
+
+	err = ImportCFP("test", "123", event, client, WithSync(true))
+	require.NoError(t, err)
+	err = ImportCFP("test", "123", event, client, WithSync(true))
+	require.NoError(t, err)
+
+	assert.Len(t, client.Boards, 1)
+	assert.Contains(t, client.Boards, boardName)
+	assert.Len(t, client.Cards, 8)
+}
+
+func TestImportCFP_Tiering(t *testing.T) {
+	client := trello.NewFakeClient()
+	event, err := cfp.Parse("../cfp/testdata/export.json", geo.FakeLocate)
+	require.NoError(t, err)
+
+	tiering := Tiering{Strategy: TieringThresholds, Values: []float64{4, 3}}
+	err = ImportCFP("test", "123", event, client, WithFormatTiering("Format 1", tiering))
+	require.NoError(t, err)
+
+	boardName := "Délibération Awesome Conference 2042 - Format 1"
+	assert.Len(t, client.Lists, 11)
+	assert.Len(t, client.Lists[boardName+"-Category 1 - T1"], 1)
+	assert.Len(t, client.Lists[boardName+"-Category 1 - T2"], 3)
+	assert.Len(t, client.Lists[boardName+"-Category 1 - T3"], 1)
+	assert.Len(t, client.Lists[boardName+"-Category 2 - T1"], 2)
+	assert.Len(t, client.Lists[boardName+"-Category 2 - T2"], 0)
+	assert.Len(t, client.Lists[boardName+"-Category 2 - T3"], 1)
+	assert.NotContains(t, client.Lists, boardName+"-T3")
+}
+
+func TestParseTiering(t *testing.T) {
+	tests := []struct {
+		name    string
+		tiering string
+		want    Tiering
+		wantErr bool
+	}{
+		{name: "Equal", tiering: "equal:3", want: DefaultTiering},
+		{name: "Percentiles", tiering: "percentiles:20,50", want: Tiering{Strategy: TieringPercentiles, Values: []float64{20, 50}, MergeLastTier: true}},
+		{name: "Thresholds keeping last tier", tiering: "thresholds:4,3:keep", want: Tiering{Strategy: TieringThresholds, Values: []float64{4, 3}}},
+		{name: "Top", tiering: "top:2:merge", want: Tiering{Strategy: TieringTop, Values: []float64{2}, MergeLastTier: true}},
+		{name: "Unknown strategy", tiering: "random:3", wantErr: true},
+		{name: "Missing values", tiering: "equal", wantErr: true},
+		{name: "Invalid tier count", tiering: "equal:1.5", wantErr: true},
+		{name: "Increasing thresholds", tiering: "thresholds:3,4", wantErr: true},
+		{name: "Invalid percentiles", tiering: "percentiles:50,120", wantErr: true},
+		{name: "Invalid last tier option", tiering: "top:2:drop", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tiering, err := ParseTiering(tc.tiering)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, tiering)
+		})
+	}
+}
+
+func TestTiering_Split(t *testing.T) {
+	ratings := func(ratings ...float64) []cfp.Proposal {
+		proposals := make([]cfp.Proposal, 0, len(ratings))
+		for _, rating := range ratings {
+			proposals = append(proposals, cfp.Proposal{Rating: rating})
+		}
+		return proposals
+	}
+
+	tests := []struct {
+		name      string
+		tiering   Tiering
+		proposals []cfp.Proposal
+		want      []int
+	}{
+		{name: "Equal", tiering: DefaultTiering, proposals: ratings(5, 4, 3, 2, 1), want: []int{2, 2, 1}},
+		{name: "Equal with a single proposal", tiering: DefaultTiering, proposals: ratings(5), want: []int{1, 0, 0}},
+		{name: "Percentiles", tiering: Tiering{Strategy: TieringPercentiles, Values: []float64{20, 50}}, proposals: ratings(5, 4, 3, 2, 1), want: []int{1, 2, 2}},
+		{name: "Thresholds", tiering: Tiering{Strategy: TieringThresholds, Values: []float64{4, 3}}, proposals: ratings(5, 4, 3.5, 2, 1), want: []int{2, 1, 2}},
+		{name: "Top", tiering: Tiering{Strategy: TieringTop, Values: []float64{2}}, proposals: ratings(5, 4, 3, 2, 1), want: []int{2, 3}},
+		{name: "Top with fewer proposals", tiering: Tiering{Strategy: TieringTop, Values: []float64{2}}, proposals: ratings(5), want: []int{1, 0}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tiers := tc.tiering.Split(tc.proposals)
+			sizes := make([]int, 0, len(tiers))
+			for _, tier := range tiers {
+				sizes = append(sizes, len(tier))
+			}
+			assert.Equal(t, tc.want, sizes)
+		})
+	}
+}
+
+func TestImportCFP_Layout(t *testing.T) {
+	client := trello.NewFakeClient()
+	event, err := cfp.Parse("../cfp/testdata/export.json", geo.FakeLocate)
+	require.NoError(t, err)
+	l, err := layout.Load("../layout/testdata/layout.json")
+	require.NoError(t, err)
+
+	err = ImportCFP("test", "123", event, client, WithLayout(l))
+	require.NoError(t, err)
+
+	boardName := "Awesome Conference 2042 / Format 1"
+	assert.Equal(
+		t,
+		[]string{"To discuss", "Category 1 - T1", "Category 1 - T2", "Category 2 - T1", "Category 2 - T2", "T3", "Accepted", "Rejected"},
+		listNames(client.Boards[boardName]),
+	)
+	assert.Len(t, client.Labels, 5)
+	assert.Equal(t, trello.ColorBlue, client.Labels["Category 1"])
+	assert.Equal(t, trello.ColorLime, client.Labels["🇫🇷"])
+	assert.Equal(t, []string{"Category 1", "🇫🇷"}, client.Cards["A beginner talk in category 1"].IDLabels)
+}
+
+func listNames(lists []trello.List) []string {
+	names := make([]string, 0, len(lists))
+	for _, list := range lists {
+		names = append(names, list.Name)
+	}
+	return names
+}
+
+// failingClient fails to create cards once a given number of them have been created.
+type failingClient struct {
+	trello.FakeClient
+	remainingCards int
+}
+
+func (c *failingClient) CreateCard(name, desc string, list trello.List, labels []trello.Label) (trello.Card, error) {
+	if c.remainingCards == 0 {
+		return trello.Card{}, errors.New("network blip")
+	}
+	c.remainingCards--
+	return c.FakeClient.CreateCard(name, desc, list, labels)
+}
+
+// coverlessClient fails to set covers, as Trello does when a photo can't be downloaded.
+type coverlessClient struct {
+	trello.FakeClient
+}
+
+func (c coverlessClient) SetCover(trello.Card, string) error {
+	return errors.New("invalid image")
+}
+
+func TestImportCFP_CoverFailure(t *testing.T) {
+	client := coverlessClient{FakeClient: trello.NewFakeClient()}
+	event, err := cfp.Parse("../cfp/testdata/export.json", geo.FakeLocate)
+	require.NoError(t, err)
+
+	err = ImportCFP("test", "123", event, client)
+	require.NoError(t, err)
+
+	assert.Len(t, client.Cards, 8)
+	assert.Empty(t, client.Covers)
+}
+
+func TestImportCFP_Resume(t *testing.T) {
+	client := trello.NewFakeClient()
+	event, err := cfp.Parse("../cfp/testdata/export.json", geo.FakeLocate)
+	require.NoError(t, err)
+	journalPath := filepath.Join(t.TempDir(), "import.journal")
+
+	journal, err := OpenJournal(journalPath, false)
+	require.NoError(t, err)
+	err = ImportCFP("test", "123", event, &failingClient{FakeClient: client, remainingCards: 4}, WithJournal(journal))
+	require.EqualError(t, err, "network blip")
+	require.NoError(t, journal.Close())
+	assert.Len(t, client.Cards, 4)
+
+	journal, err = OpenJournal(journalPath, true)
+	require.NoError(t, err)
+	t.Cleanup(func() { journal.Close() })
+	assert.Positive(t, journal.Len())
+	err = ImportCFP("test", "123", event, client, WithJournal(journal))
+	require.NoError(t, err)
+
+	assert.Len(t, client.Boards, 1)
+	assert.Len(t, client.Boards["Délibération Awesome Conference 2042 - Format 1"], 10)
+	assert.Len(t, client.Lists, 10)
+	assert.Len(t, client.Cards, 8)
+	assert.Len(t, client.Labels, 8)
+	assert.Len(t, client.CustomFieldValues, 8)
+	assert.Len(t, client.Comments["Another beginner talk in category 1"], 2)
+}
+
+func TestOpenJournal_NothingToResume(t *testing.T) {
+	_, err := OpenJournal(filepath.Join(t.TempDir(), "import.journal"), true)
+
+	assert.Error(t, err)
+}
+
+func TestImportCFP_Anonymous(t *testing.T) {
+	client := trello.NewFakeClient()
+	event, err := cfp.Parse("../cfp/testdata/export.json", geo.FakeLocate)
+	require.NoError(t, err)
+	l := layout.Default()
+	l.Labels = append(l.Labels, layout.Label{Marker: layout.MarkerSpeakers, Color: trello.ColorOrange})
+	path := filepath.Join(t.TempDir(), "speakers.json")
+	mapping, err := OpenSpeakerMapping(path)
+	require.NoError(t, err)
+	for i, p := range event.Proposals {
+		if p.ID == "6grkSZ4ArcYr8BZfcw0o" {
+			event.Proposals[i].OrganizerMessages = []string{"Leala Simard already spoke at Gold Medal meetups\n--\n**Orga One** _le 04/08 à 11h44_"}
+		}
+	}
+
+	err = ImportCFP("test", "123", event, client, WithLayout(l), WithAnonymous(mapping, true))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"███ already spoke at ███ meetups\n--\n**Orga One** _le 04/08 à 11h44_"}, client.Comments["A beginner talk in category 1"])
+
+	assert.Len(t, client.Cards, 8)
+	for _, card := range client.Cards {
+		for _, speaker := range []string{"Leala", "Kari", "Anne", "Benjamin", "Dev from UK", "Gold Medal", "Carpentras"} {
+			assert.NotContains(t, card.Desc, speaker)
+		}
+		assert.NotContains(t, card.Desc, "🎤")
+		assert.NotContains(t, client.CustomFieldValues[card.ID], "Speakers")
+	}
+	assert.NotContains(t, client.Cards["A talk in category 2"].Desc, "Two speakers, one without address")
+	assert.Empty(t, client.Covers)
+	for label := range client.Labels {
+		assert.NotContains(t, label, " - ")
+	}
+	assert.Len(t, mapping.Cards, 8)
+	assert.Equal(
+		t,
+		HiddenSpeakers{BoardID: "Délibération Awesome Conference 2042 - Format 1", ProposalID: "6grkSZ4ArcYr8BZfcw0o", Speakers: "Leala Simard - Carpentras, France (Gold Medal)"},
+		mapping.Cards["A beginner talk in category 1"],
+	)
+
+	mapping, err = LoadSpeakerMapping(path)
+	require.NoError(t, err)
+	assert.Len(t, mapping.Cards, 8)
+	require.NoError(t, Reveal(mapping, client, WithLayout(l)))
+
+	card := client.Cards["A beginner talk in category 1"]
+	assert.Contains(t, card.IDLabels, "Leala Simard - Carpentras, France (Gold Medal)")
+	assert.Equal(t, trello.ColorOrange, client.Labels["Leala Simard - Carpentras, France (Gold Medal)"])
+	assert.Equal(t, "Leala Simard - Carpentras, France (Gold Medal)", client.CustomFieldValues[card.ID]["Speakers"])
+
+	// revealing again, e.g. after an interrupted reveal, leaves the cards as they are
+	require.NoError(t, Reveal(mapping, client, WithLayout(l)))
+	assert.Equal(t, card, client.Cards["A beginner talk in category 1"])
+}
+
+func TestOpenSpeakerMapping_Merge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "speakers.json")
+	mapping, err := OpenSpeakerMapping(path)
+	require.NoError(t, err)
+	require.NoError(t, mapping.record("card1", HiddenSpeakers{BoardID: "Conference", ProposalID: "1", Speakers: "Jane"}))
+
+	// e.g. a later import of another format
+	mapping, err = OpenSpeakerMapping(path)
+	require.NoError(t, err)
+	require.NoError(t, mapping.record("card2", HiddenSpeakers{BoardID: "Quickie", ProposalID: "2", Speakers: "John"}))
+
+	mapping, err = LoadSpeakerMapping(path)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		map[string]HiddenSpeakers{
+			"card1": {BoardID: "Conference", ProposalID: "1", Speakers: "Jane"},
+			"card2": {BoardID: "Quickie", ProposalID: "2", Speakers: "John"},
+		},
+		mapping.Cards,
+	)
+}
@@ -0,0 +1,128 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+type journalKind string
+
+const (
+	journalBoard      journalKind = "board"
+	journalList       journalKind = "list"
+	journalLabel      journalKind = "label"
+	journalField      journalKind = "field"
+	journalCard       journalKind = "card"
+	journalFieldValue journalKind = "field-value"
+	journalComment    journalKind = "comment"
+	journalCover      journalKind = "cover"
+)
+
+// Journal records every Trello element created during an import, one JSON entry per line, so that an interrupted
+// import can be resumed without creating the same elements again.
+// A nil Journal records nothing.
+type Journal struct {
+	file    *os.File
+	entries map[string]string
+	mu      sync.Mutex
+}
+
+type journalEntry struct {
+	Kind journalKind `json:"kind"`
+	Key  string      `json:"key"`
+	ID   string      `json:"id"`
+}
+
+// OpenJournal opens the journal at path.
+// When resuming, the entries already recorded are read, otherwise the journal is started over.
+func OpenJournal(path string, resume bool) (*Journal, error) {
+	j := &Journal{entries: make(map[string]string)}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		if err := j.read(path); err != nil {
+			return nil, err
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return nil, err
+	}
+	j.file = f
+	return j, nil
+}
+
+func (j *Journal) read(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no journal to resume from at %s", path)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// the last entry might have been partially written when the import was interrupted
+			continue
+		}
+		j.entries[journalKey(entry.Kind, entry.Key)] = entry.ID
+	}
+	return scanner.Err()
+}
+
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.file.Close()
+}
+
+// Len returns the number of recorded entries.
+func (j *Journal) Len() int {
+	if j == nil {
+		return 0
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.entries)
+}
+
+func (j *Journal) lookup(kind journalKind, key string) (string, bool) {
+	if j == nil {
+		return "", false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	id, ok := j.entries[journalKey(kind, key)]
+	return id, ok
+}
+
+func (j *Journal) record(kind journalKind, key, id string) error {
+	if j == nil {
+		return nil
+	}
+	data, err := json.Marshal(journalEntry{Kind: kind, Key: key, ID: id})
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	j.entries[journalKey(kind, key)] = id
+	return nil
+}
+
+func journalKey(kind journalKind, key string) string {
+	return string(kind) + "|" + key
+}
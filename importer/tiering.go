@@ -0,0 +1,150 @@
+package importer
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bdxio/cfp-to-trello/cfp"
+)
+
+type TieringStrategy string
+
+const (
+	// TieringEqual splits the proposals of a category into the given number of tiers of equal size.
+	TieringEqual TieringStrategy = "equal"
+	// TieringPercentiles splits the proposals of a category at the given percentiles, e.g. 20,50.
+	TieringPercentiles TieringStrategy = "percentiles"
+	// TieringThresholds splits the proposals of a category at the given ratings, e.g. 4,3.
+	TieringThresholds TieringStrategy = "thresholds"
+	// TieringTop puts the given number of top-rated proposals of a category in the first tier, the others in the second one.
+	TieringTop TieringStrategy = "top"
+)
+
+// Tiering defines how the proposals of a category are split into deliberation lists.
+type Tiering struct {
+	Strategy TieringStrategy
+	Values   []float64
+	// MergeLastTier puts the last tier proposals of all categories together in a single list.
+	MergeLastTier bool
+}
+
+// DefaultTiering splits proposals in three tiers of equal size, all last tier proposals being put together.
+var DefaultTiering = Tiering{Strategy: TieringEqual, Values: []float64{3}, MergeLastTier: true}
+
+// ParseTiering parses a tiering formatted as strategy:values[:merge|keep], e.g. "thresholds:4,3:keep".
+// The last tier is merged if not specified.
+func ParseTiering(s string) (Tiering, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Tiering{}, fmt.Errorf("invalid tiering %q, expected strategy:values[:merge|keep]", s)
+	}
+
+	tiering := Tiering{Strategy: TieringStrategy(parts[0]), MergeLastTier: true}
+	for _, v := range strings.Split(parts[1], ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return Tiering{}, fmt.Errorf("invalid tiering value %q: %w", v, err)
+		}
+		tiering.Values = append(tiering.Values, value)
+	}
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "merge":
+			tiering.MergeLastTier = true
+		case "keep":
+			tiering.MergeLastTier = false
+		default:
+			return Tiering{}, fmt.Errorf("invalid last tier option %q, expected merge or keep", parts[2])
+		}
+	}
+
+	if err := tiering.validate(); err != nil {
+		return Tiering{}, err
+	}
+	return tiering, nil
+}
+
+func (t Tiering) validate() error {
+	switch t.Strategy {
+	case TieringEqual, TieringTop:
+		if len(t.Values) != 1 || t.Values[0] < 1 || t.Values[0] != math.Trunc(t.Values[0]) {
+			return fmt.Errorf("%s tiering expects a single positive integer", t.Strategy)
+		}
+	case TieringPercentiles:
+		for i, v := range t.Values {
+			if v <= 0 || v >= 100 || (i > 0 && v <= t.Values[i-1]) {
+				return fmt.Errorf("%s tiering expects increasing percentiles between 0 and 100", t.Strategy)
+			}
+		}
+	case TieringThresholds:
+		for i, v := range t.Values {
+			if i > 0 && v >= t.Values[i-1] {
+				return fmt.Errorf("%s tiering expects decreasing ratings", t.Strategy)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown tiering strategy %q", t.Strategy)
+	}
+	return nil
+}
+
+// Tiers returns the number of tiers.
+func (t Tiering) Tiers() int {
+	switch t.Strategy {
+	case TieringEqual:
+		return int(t.Values[0])
+	case TieringTop:
+		return 2
+	default:
+		return len(t.Values) + 1
+	}
+}
+
+// Split splits proposals, sorted from top-rated to lower rated, into tiers.
+// Tiers might be empty.
+func (t Tiering) Split(proposals []cfp.Proposal) [][]cfp.Proposal {
+	cuts := t.cuts(proposals)
+	tiers := make([][]cfp.Proposal, 0, len(cuts)+1)
+	start := 0
+	for _, cut := range cuts {
+		if cut > len(proposals) {
+			cut = len(proposals)
+		}
+		if cut < start {
+			cut = start
+		}
+		tiers = append(tiers, proposals[start:cut])
+		start = cut
+	}
+	return append(tiers, proposals[start:])
+}
+
+// cuts returns the index of the first proposal of each tier but the first one.
+func (t Tiering) cuts(proposals []cfp.Proposal) []int {
+	n := len(proposals)
+	cuts := make([]int, 0, t.Tiers()-1)
+	switch t.Strategy {
+	case TieringEqual:
+		size := int(math.Ceil(float64(n) / t.Values[0]))
+		for i := 1; i < t.Tiers(); i++ {
+			cuts = append(cuts, i*size)
+		}
+	case TieringPercentiles:
+		for _, p := range t.Values {
+			cuts = append(cuts, int(math.Ceil(float64(n)*p/100)))
+		}
+	case TieringThresholds:
+		for _, threshold := range t.Values {
+			cut := 0
+			for cut < n && proposals[cut].Rating >= threshold {
+				cut++
+			}
+			cuts = append(cuts, cut)
+		}
+	case TieringTop:
+		cuts = append(cuts, int(t.Values[0]))
+	}
+	return cuts
+}
@@ -0,0 +1,193 @@
+package layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bdxio/cfp-to-trello/trello"
+)
+
+const (
+	placeholderEvent  = "{event}"
+	placeholderFormat = "{format}"
+)
+
+// Role identifies the lists the importer and the publisher rely on.
+type Role string
+
+const (
+	RoleSelection       Role = "selection"
+	RoleWithdrawals     Role = "withdrawals"
+	RoleAcceptedBackups Role = "accepted-backups"
+	RoleBackups         Role = "backups"
+	RoleRejections      Role = "rejections"
+	// RoleTiers is the position of the tier lists, it has no name.
+	RoleTiers Role = "tiers"
+)
+
+// Marker identifies the proposal information put as a label on cards.
+type Marker string
+
+const (
+	MarkerCategory Marker = "category"
+	MarkerRating   Marker = "rating"
+	MarkerVotes    Marker = "votes"
+	MarkerSpeakers Marker = "speakers"
+	MarkerLevel    Marker = "level"
+	MarkerLanguage Marker = "language"
+)
+
+var markers = map[Marker]struct{}{
+	MarkerCategory: {},
+	MarkerRating:   {},
+	MarkerVotes:    {},
+	MarkerSpeakers: {},
+	MarkerLevel:    {},
+	MarkerLanguage: {},
+}
+
+// Layout describes the deliberation boards: their name, their lists and the labels put on cards.
+type Layout struct {
+	// BoardName is the pattern of board names, {event} and {format} being replaced by the event and format names.
+	BoardName string  `json:"boardName"`
+	Lists     []List  `json:"lists"`
+	Labels    []Label `json:"labels"`
+}
+
+// List is a list of the board, in order of appearance.
+type List struct {
+	Name string `json:"name"`
+	Role Role   `json:"role,omitempty"`
+}
+
+// Label is a label put on proposal cards.
+type Label struct {
+	Marker Marker       `json:"marker"`
+	Color  trello.Color `json:"color"`
+}
+
+// Default returns the layout used by BDX I/O.
+func Default() Layout {
+	return Layout{
+		BoardName: "Délibération {event} - {format}",
+		Lists: []List{
+			{Name: trello.ListSelection, Role: RoleSelection},
+			{Name: "Désistements", Role: RoleWithdrawals},
+			{Name: "Backups Acceptés", Role: RoleAcceptedBackups},
+			{Name: "Backups", Role: RoleBackups},
+			{Role: RoleTiers},
+			{Name: trello.ListRefuses, Role: RoleRejections},
+		},
+		Labels: []Label{
+			{Marker: MarkerCategory, Color: trello.ColorGreen},
+			{Marker: MarkerLevel, Color: trello.ColorSky},
+			{Marker: MarkerLanguage, Color: trello.ColorPink},
+		},
+	}
+}
+
+// Load reads a layout from a JSON file.
+func Load(path string) (Layout, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Layout{}, err
+	}
+	var l Layout
+	if err := json.Unmarshal(data, &l); err != nil {
+		return Layout{}, err
+	}
+	if err := l.validate(); err != nil {
+		return Layout{}, fmt.Errorf("invalid layout %s: %w", path, err)
+	}
+	return l, nil
+}
+
+func (l Layout) validate() error {
+	if !strings.Contains(l.BoardName, placeholderEvent) || !strings.Contains(l.BoardName, placeholderFormat) {
+		return fmt.Errorf("board name %q must contain %s and %s", l.BoardName, placeholderEvent, placeholderFormat)
+	}
+
+	roles := make(map[Role]struct{})
+	names := make(map[string]struct{})
+	for _, list := range l.Lists {
+		if list.Role != "" {
+			if _, ok := roles[list.Role]; ok {
+				return fmt.Errorf("role %s is used by several lists", list.Role)
+			}
+			roles[list.Role] = struct{}{}
+		}
+		if list.Role == RoleTiers {
+			continue
+		}
+		if list.Name == "" {
+			return fmt.Errorf("list with role %q has no name", list.Role)
+		}
+		if _, ok := names[list.Name]; ok {
+			return fmt.Errorf("list %s is declared several times", list.Name)
+		}
+		names[list.Name] = struct{}{}
+	}
+	if _, ok := roles[RoleTiers]; !ok {
+		return fmt.Errorf("no list with role %s", RoleTiers)
+	}
+
+	for _, label := range l.Labels {
+		if _, ok := markers[label.Marker]; !ok {
+			return fmt.Errorf("unknown label marker %q", label.Marker)
+		}
+	}
+	return nil
+}
+
+// BoardNameFor returns the name of the board of a format.
+func (l Layout) BoardNameFor(eventName, format string) string {
+	return strings.NewReplacer(placeholderEvent, eventName, placeholderFormat, format).Replace(l.BoardName)
+}
+
+// ListName returns the name of the list having the given role.
+func (l Layout) ListName(role Role) (string, bool) {
+	for _, list := range l.Lists {
+		if list.Role == role {
+			return list.Name, true
+		}
+	}
+	return "", false
+}
+
+// IsTierList returns true if the list is not one of the named lists of the layout.
+func (l Layout) IsTierList(name string) bool {
+	for _, list := range l.Lists {
+		if list.Role != RoleTiers && list.Name == name {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterBoards returns the open boards of the given formats of an event.
+func (l Layout) FilterBoards(boards []trello.Board, eventName string, formats []string) []trello.Board {
+	open := make([]trello.Board, 0, len(boards))
+	for _, board := range l.FilterAllBoards(boards, eventName, formats) {
+		if !board.Closed {
+			open = append(open, board)
+		}
+	}
+	return open
+}
+
+// FilterAllBoards returns the boards of the given formats of an event, closed ones included.
+func (l Layout) FilterAllBoards(boards []trello.Board, eventName string, formats []string) []trello.Board {
+	boardNames := make(map[string]struct{})
+	for _, format := range formats {
+		boardNames[l.BoardNameFor(eventName, format)] = struct{}{}
+	}
+	cfpBoards := make([]trello.Board, 0)
+	for _, board := range boards {
+		if _, ok := boardNames[board.Name]; ok {
+			cfpBoards = append(cfpBoards, board)
+		}
+	}
+	return cfpBoards
+}
@@ -0,0 +1,80 @@
+package layout
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdxio/cfp-to-trello/trello"
+)
+
+func TestLoad(t *testing.T) {
+	l, err := Load("testdata/layout.json")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Awesome Conference 2042 / Format 1", l.BoardNameFor("Awesome Conference 2042", "Format 1"))
+	assert.Equal(
+		t,
+		[]List{{Name: "To discuss"}, {Role: RoleTiers}, {Name: "Accepted", Role: RoleSelection}, {Name: "Rejected", Role: RoleRejections}},
+		l.Lists,
+	)
+	assert.Equal(t, []Label{{Marker: MarkerCategory, Color: trello.ColorBlue}, {Marker: MarkerLanguage, Color: trello.ColorLime}}, l.Labels)
+	name, ok := l.ListName(RoleSelection)
+	assert.True(t, ok)
+	assert.Equal(t, "Accepted", name)
+	_, ok = l.ListName(RoleBackups)
+	assert.False(t, ok)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout string
+	}{
+		{name: "Missing format in board name", layout: `{"boardName": "{event}", "lists": [{"role": "tiers"}]}`},
+		{name: "Missing tiers", layout: `{"boardName": "{event} - {format}", "lists": [{"name": "Sélection"}]}`},
+		{name: "Duplicated role", layout: `{"boardName": "{event} - {format}", "lists": [{"role": "tiers"}, {"role": "tiers"}]}`},
+		{name: "Duplicated list", layout: `{"boardName": "{event} - {format}", "lists": [{"role": "tiers"}, {"name": "A"}, {"name": "A"}]}`},
+		{name: "Unnamed list", layout: `{"boardName": "{event} - {format}", "lists": [{"role": "tiers"}, {"role": "selection"}]}`},
+		{name: "Unknown marker", layout: `{"boardName": "{event} - {format}", "lists": [{"role": "tiers"}], "labels": [{"marker": "unknown"}]}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "layout.json")
+			require.NoError(t, os.WriteFile(path, []byte(tc.layout), 0600))
+
+			_, err := Load(path)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLayout_IsTierList(t *testing.T) {
+	l := Default()
+
+	assert.True(t, l.IsTierList("T1"))
+	assert.True(t, l.IsTierList("Category 1 - T2"))
+	assert.False(t, l.IsTierList("Backups"))
+	assert.False(t, l.IsTierList(trello.ListRefuses))
+}
+
+func TestLayout_FilterBoards(t *testing.T) {
+	boards := []trello.Board{
+		{ID: "1", Name: "Délibération Awesome Conference 2042 - Format 1"},
+		{ID: "2", Name: "Délibération Awesome Conference 2042 - Format 2"},
+		{ID: "3", Name: "Délibération Another Conference - Format 1"},
+		{ID: "4", Name: "Another unrelated board"},
+		{ID: "5", Name: "Délibération Awesome Conference 2042 - Format 1", Closed: true},
+	}
+
+	filtered := Default().FilterBoards(boards, "Awesome Conference 2042", []string{"Format 1", "Format 2"})
+	assert.Equal(t, boards[:2], filtered)
+
+	filtered = Default().FilterAllBoards(boards, "Awesome Conference 2042", []string{"Format 1", "Format 2"})
+	assert.Equal(t, []trello.Board{boards[0], boards[1], boards[4]}, filtered)
+}
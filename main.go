@@ -1,15 +1,23 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/bdxio/cfp-to-trello/cfp"
+	"github.com/bdxio/cfp-to-trello/cleanup"
+	"github.com/bdxio/cfp-to-trello/diff"
 	"github.com/bdxio/cfp-to-trello/geo"
 	"github.com/bdxio/cfp-to-trello/importer"
+	"github.com/bdxio/cfp-to-trello/layout"
 	"github.com/bdxio/cfp-to-trello/publisher"
+	"github.com/bdxio/cfp-to-trello/stats"
 	"github.com/bdxio/cfp-to-trello/trello"
 )
 
@@ -20,10 +28,46 @@ func main() {
 	var eventID string
 	var jsonPath string
 	var cfpKey string
+	var cfpAPI string
+	var sourceName string
+	var eventName string
+	var layoutPath string
 	var importCFP bool
+	var sync bool
+	var resume bool
+	var statePath string
+	var anonymous bool
+	var dropMessages bool
+	var mappingPath string
+	var reveal bool
+	var tierings tieringFlag
+	var states string
+	var formats string
+	var categories string
+	var languages string
+	var languageMappingPath string
 	var accept bool
+	var acceptBackups bool
 	var reject bool
+	var rejectRemaining bool
+	var force bool
+	var validate bool
+	var syncBack bool
+	var maxTalksPerSpeaker int
+	var quotas string
 	var dryRun bool
+	var continueOnError bool
+	var reportPath string
+	var yes bool
+	var publishedLabel bool
+	var cleanupBoards bool
+	var showStats bool
+	var showDiff bool
+	var previousPath string
+	var ratingThreshold float64
+	var diffFormat string
+	var statsFormat string
+	var deleteBoards bool
 
 	flag.StringVar(&organizationName, "org", "bdxio", "Organization name in Trello")
 	flag.StringVar(&trelloKey, "trello-key", "", "Trello consumer key")
@@ -31,44 +75,272 @@ func main() {
 	flag.StringVar(&eventID, "event-id", "", "Conference-Hall event ID")
 	flag.StringVar(&jsonPath, "json", "", "Path to CFP export JSON file")
 	flag.StringVar(&cfpKey, "cfp-key", "", "Conference-Hall API key")
+	flag.StringVar(&sourceName, "source", "conference-hall", "CFP platform the JSON export comes from: conference-hall, sessionize or pretalx")
+	flag.StringVar(&eventName, "event-name", "", "Event name, required for Sessionize and pretalx exports")
+	flag.StringVar(&cfpAPI, "cfp-api", string(cfp.APIv1), "Conference-Hall API version: v1, or v2 for the newer platform")
+	flag.StringVar(&layoutPath, "layout", "", "Path to board layout JSON file, BDX I/O layout is used if not set")
 	flag.BoolVar(&importCFP, "import", false, "Import CFP in Trello")
+	flag.StringVar(&states, "states", cfp.StateSubmitted, "Comma separated states of the proposals to import, all if empty (all by default for stats)")
+	flag.StringVar(&formats, "formats", "", "Comma separated formats to import, all if not set")
+	flag.StringVar(&categories, "categories", "", "Comma separated categories of the proposals to import, all if not set")
+	flag.StringVar(&languageMappingPath, "language-mapping", "", "Path to language mapping JSON file, French and English are recognized if not set")
+	flag.StringVar(&languages, "languages", "", "Comma separated languages of the proposals to import, all if not set")
+	flag.Var(&tierings, "tiering", "Tiering of deliberation lists as [format=]strategy:values[:merge|keep], e.g. thresholds:4,3 or Quickie=top:2:keep (repeatable)")
+	flag.BoolVar(&resume, "resume", false, "Resume an interrupted import from its journal")
+	flag.BoolVar(&anonymous, "anonymous", false, "Leave speakers out of the cards when importing, for a blind deliberation")
+	flag.BoolVar(&dropMessages, "drop-private-messages", false, "Drop the private messages of proposals imported anonymously instead of scrubbing them")
+	flag.StringVar(&mappingPath, "speaker-mapping", "", "Path to the mapping of the speakers left out of the cards, speakers-<event-id>.json if not set")
+	flag.BoolVar(&reveal, "reveal", false, "Put back the speakers of the cards imported anonymously")
+	flag.StringVar(&statePath, "state", "", "Path to the import journal, import-<event-id>.journal if not set")
+	flag.BoolVar(&sync, "sync", false, "Sync existing Trello boards instead of creating new ones when importing")
 	flag.BoolVar(&accept, "accept", false, "Accept proposals in CFP")
+	flag.BoolVar(&acceptBackups, "accept-backups", false, "Accept promoted backup proposals in CFP")
 	flag.BoolVar(&reject, "reject", false, "Reject proposals in CFP")
+	flag.BoolVar(&rejectRemaining, "reject-remaining", false, "Reject proposals in CFP neither selected, nor backups, nor withdrawn")
+	flag.BoolVar(&force, "force", false, "Reject remaining proposals even if tier lists still have cards")
+	flag.BoolVar(&syncBack, "sync-back", false, "Reflect on Trello cards the proposals confirmed or declined by speakers in CFP")
+	flag.BoolVar(&validate, "validate", false, "Validate the Trello boards against the CFP before publishing")
+	flag.IntVar(&maxTalksPerSpeaker, "max-talks-per-speaker", 0, "Maximum number of selected talks per speaker when validating, unlimited if 0")
+	flag.StringVar(&quotas, "quotas", "", "Comma separated slot quotas per format when validating, e.g. Conference=30,Quickie=8")
 	flag.BoolVar(&dryRun, "dry-run", false, "Don't publish proposals, only logs the requests")
+	flag.BoolVar(&publishedLabel, "published-label", false, "Label cards as published once their proposal is, and skip labelled cards")
+	flag.BoolVar(&yes, "yes", false, "Publish proposals without asking for confirmation")
+	flag.StringVar(&reportPath, "report", "", "Path to the publication report, written as JSON if it ends with .json, as CSV otherwise")
+	flag.BoolVar(&continueOnError, "continue-on-error", false, "Keep publishing proposals when one fails, failures are reported at the end")
+	flag.BoolVar(&showStats, "stats", false, "Print statistics of the CFP proposals")
+	flag.StringVar(&statsFormat, "stats-format", "text", "Format of the statistics: text, json or csv")
+	flag.BoolVar(&showDiff, "diff", false, "Print the changes of the CFP since a previous JSON export")
+	flag.StringVar(&previousPath, "previous", "", "Path to the previous CFP export JSON file to compare with")
+	flag.Float64Var(&ratingThreshold, "rating-threshold", diff.DefaultRatingThreshold, "Smallest rating move printed when comparing CFP exports")
+	flag.StringVar(&diffFormat, "diff-format", "text", "Format of the changes: text or json")
+	flag.BoolVar(&cleanupBoards, "cleanup", false, "Close the Trello boards of the CFP")
+	flag.BoolVar(&deleteBoards, "delete", false, "Permanently delete the Trello boards instead of closing them when cleaning up")
 	flag.Parse()
 
+	cfpVersion, err := cfp.ParseAPIVersion(cfpAPI)
+	if err != nil {
+		log.Fatalf("Invalid Conference-Hall API version: %v", err)
+	}
+
+	boardLayout := layout.Default()
+	if layoutPath != "" {
+		boardLayout, err = layout.Load(layoutPath)
+		if err != nil {
+			log.Fatalf("Error while loading board layout: %v", err)
+		}
+	}
+
+	languageMapping := cfp.DefaultLanguageMapping()
+	if languageMappingPath != "" {
+		languageMapping, err = cfp.LoadLanguageMapping(languageMappingPath)
+		if err != nil {
+			log.Fatalf("Error while loading language mapping: %v", err)
+		}
+	}
+
+	publishOpts := []publisher.Option{
+		publisher.WithLayout(boardLayout),
+		publisher.WithContinueOnError(continueOnError),
+		publisher.WithForce(force),
+		// nothing is published on dry runs, cards are left as is
+		publisher.WithComment(!dryRun),
+		publisher.WithPublishedLabel(publishedLabel && !dryRun),
+	}
+	if yes || dryRun {
+		// the plan is still logged
+		publishOpts = append(publishOpts, publisher.WithConfirm(func(string) bool { return true }))
+	} else {
+		publishOpts = append(publishOpts, publisher.WithConfirm(confirm))
+	}
+	if reportPath != "" && (accept || acceptBackups || reject || rejectRemaining) {
+		report, err := os.Create(reportPath)
+		if err != nil {
+			log.Fatalf("Error while creating publication report: %v", err)
+		}
+		defer report.Close()
+		format := publisher.ReportCSV
+		if strings.EqualFold(filepath.Ext(reportPath), ".json") {
+			format = publisher.ReportJSON
+		}
+		publishOpts = append(publishOpts, publisher.WithReport(report, format))
+	}
+
+	filter := cfp.Filter{
+		States:     splitList(states),
+		Formats:    splitList(formats),
+		Categories: splitList(categories),
+		Languages:  splitList(languages),
+
+		LanguageMapping: languageMapping,
+	}
+
 	switch {
 	case importCFP:
-		runImport(organizationName, trelloKey, trelloSecret, eventID, jsonPath)
+		runImport(organizationName, trelloKey, trelloSecret, eventID, newSource(sourceName, eventID, eventName, jsonPath, cfpKey, cfpVersion), statePath, boardLayout, filter, sync, resume, tierings, anonymous, dropMessages, mappingPath)
 	case accept:
-		runPublish(organizationName, trelloKey, trelloSecret, eventID, cfpKey, publisher.PublicationAccept, dryRun)
+		runPublish(organizationName, trelloKey, trelloSecret, eventID, cfpKey, cfpVersion, publisher.PublicationAccept, dryRun, publishOpts...)
+	case acceptBackups:
+		runPublish(organizationName, trelloKey, trelloSecret, eventID, cfpKey, cfpVersion, publisher.PublicationAcceptBackups, dryRun, publishOpts...)
 	case reject:
-		runPublish(organizationName, trelloKey, trelloSecret, eventID, cfpKey, publisher.PublicationReject, dryRun)
+		runPublish(organizationName, trelloKey, trelloSecret, eventID, cfpKey, cfpVersion, publisher.PublicationReject, dryRun, publishOpts...)
+	case rejectRemaining:
+		runPublish(organizationName, trelloKey, trelloSecret, eventID, cfpKey, cfpVersion, publisher.PublicationRejectRemaining, dryRun, publishOpts...)
+	case validate:
+		rules, err := parseRules(maxTalksPerSpeaker, quotas)
+		if err != nil {
+			log.Fatalf("Invalid validation rules: %v", err)
+		}
+		runValidate(organizationName, trelloKey, trelloSecret, eventID, cfpKey, cfpVersion, boardLayout, rules)
+	case syncBack:
+		runSyncBack(organizationName, trelloKey, trelloSecret, eventID, cfpKey, cfpVersion, boardLayout)
+	case reveal:
+		runReveal(trelloKey, trelloSecret, eventID, mappingPath, boardLayout)
+	case showDiff:
+		runDiff(previousPath, eventID, jsonPath, cfpKey, cfpVersion, ratingThreshold, diff.Format(diffFormat))
+	case showStats:
+		format, err := stats.ParseFormat(statsFormat)
+		if err != nil {
+			log.Fatalf("Invalid statistics format: %v", err)
+		}
+		statsFilter := filter
+		if !isFlagSet("states") {
+			// statistics cover all the proposals unless states are given
+			statsFilter.States = nil
+		}
+		runStats(newSource(sourceName, eventID, eventName, jsonPath, cfpKey, cfpVersion), statsFilter, format)
+	case cleanupBoards:
+		runCleanup(organizationName, trelloKey, trelloSecret, newSource(sourceName, eventID, eventName, jsonPath, cfpKey, cfpVersion), boardLayout, deleteBoards)
 	default:
-		fmt.Println("One action is required: import, accept or reject")
+		fmt.Println("One action is required: import, validate, accept, accept-backups, reject, reject-remaining, sync-back, reveal, stats, diff or cleanup")
 		flag.Usage()
 		os.Exit(1)
 	}
 }
 
-func runImport(organizationName, trelloKey, trelloSecret, eventID, jsonPath string) {
+func runImport(organizationName, trelloKey, trelloSecret, eventID string, source cfp.Source, statePath string, boardLayout layout.Layout, filter cfp.Filter, sync, resume bool, tierings tieringFlag, anonymous, dropMessages bool, mappingPath string) {
 	requireArg(organizationName, "org")
 	requireArg(trelloKey, "trello-key")
 	requireArg(trelloSecret, "trello-secret")
 	requireArg(eventID, "event-id")
-	requireArg(jsonPath, "json")
+
+	event := loadEvent(source, filter)
 
 	client, err := trello.New(trelloKey, trelloSecret)
 	if err != nil {
 		log.Fatalf("Error while creating Trello Client: %v", err)
 	}
 
-	if err := importer.ImportCFP(organizationName, eventID, jsonPath, geo.FindLocation, client); err != nil {
-		log.Fatalf("Error while importing CFP into Trello: %v", err)
+	if statePath == "" {
+		statePath = fmt.Sprintf("import-%s.journal", eventID)
+	}
+	journal, err := importer.OpenJournal(statePath, resume)
+	if err != nil {
+		log.Fatalf("Error while opening import journal: %v", err)
+	}
+	defer journal.Close()
+
+	opts := append([]importer.Option{importer.WithSync(sync), importer.WithLayout(boardLayout), importer.WithJournal(journal)}, tierings.options...)
+	if anonymous {
+		speakers, err := importer.OpenSpeakerMapping(speakerMappingPath(mappingPath, eventID))
+		if err != nil {
+			log.Fatalf("Error while opening speaker mapping: %v", err)
+		}
+		opts = append(opts, importer.WithAnonymous(speakers, dropMessages))
+	}
+	err = importer.ImportCFP(organizationName, eventID, event, client, opts...)
+	if err != nil {
+		journal.Close()
+		log.Fatalf("Error while importing CFP into Trello, run again with -resume to resume it: %v", err)
+	}
+}
+
+func runReveal(trelloKey, trelloSecret, eventID, mappingPath string, boardLayout layout.Layout) {
+	requireArg(trelloKey, "trello-key")
+	requireArg(trelloSecret, "trello-secret")
+	if mappingPath == "" {
+		requireArg(eventID, "event-id or speaker-mapping")
+	}
+
+	speakers, err := importer.LoadSpeakerMapping(speakerMappingPath(mappingPath, eventID))
+	if err != nil {
+		log.Fatalf("Error while loading speaker mapping: %v", err)
+	}
+
+	client, err := trello.New(trelloKey, trelloSecret)
+	if err != nil {
+		log.Fatalf("Error while creating Trello Client: %v", err)
+	}
+
+	if err := importer.Reveal(speakers, client, importer.WithLayout(boardLayout)); err != nil {
+		log.Fatalf("Error while revealing speakers: %v", err)
 	}
 }
 
-func runPublish(organizationName, trelloKey, trelloSecret, eventID, cfpKey string, pub publisher.Publication, dryRun bool) {
+// speakerMappingPath returns the path of the speaker mapping of an event, the default one if not set.
+func speakerMappingPath(path, eventID string) string {
+	if path != "" {
+		return path
+	}
+	return fmt.Sprintf("speakers-%s.json", eventID)
+}
+
+func runStats(source cfp.Source, filter cfp.Filter, format stats.Format) {
+	event := loadEvent(source, filter)
+	if err := stats.Compute(event).Write(os.Stdout, format); err != nil {
+		log.Fatalf("Error while writing CFP statistics: %v", err)
+	}
+}
+
+func runDiff(previousPath, eventID, jsonPath, cfpKey string, cfpVersion cfp.APIVersion, ratingThreshold float64, format diff.Format) {
+	requireArg(previousPath, "previous")
+
+	before, err := cfp.ConferenceHallFile{Path: previousPath, Version: cfpVersion}.Export()
+	if err != nil {
+		log.Fatalf("Error while reading previous CFP export: %v", err)
+	}
+	var after cfp.Export
+	if jsonPath != "" {
+		after, err = cfp.ConferenceHallFile{Path: jsonPath, Version: cfpVersion}.Export()
+	} else {
+		requireArg(eventID, "event-id")
+		requireArg(cfpKey, "json or cfp-key")
+		after, err = cfp.NewConferenceHallClient(
+			cfp.WithURL(cfp.URL),
+			cfp.WithEventID(eventID),
+			cfp.WithAPIKey(cfpKey),
+			cfp.WithAPIVersion(cfpVersion),
+		).GetExport()
+	}
+	if err != nil {
+		log.Fatalf("Error while loading CFP export: %v", err)
+	}
+
+	if err := diff.Compare(before, after, diff.WithRatingThreshold(ratingThreshold)).Write(os.Stdout, format); err != nil {
+		log.Fatalf("Error while writing CFP changes: %v", err)
+	}
+}
+
+// loadEvent loads the event of the CFP, restricted to the proposals selected by the filter.
+// Warnings found while parsing are logged.
+func loadEvent(source cfp.Source, filter cfp.Filter) cfp.Event {
+	event, err := source.Event(geo.FindLocation, cfp.WithLanguageMapping(filter.LanguageMapping))
+	if err != nil {
+		log.Fatalf("Error while loading CFP: %v", err)
+	}
+	if len(event.Warnings) > 0 {
+		log.Printf("%d warnings while loading CFP:", len(event.Warnings))
+		for _, warning := range event.Warnings {
+			log.Printf("  - %s", warning)
+		}
+	}
+	event, err = event.Filter(filter)
+	if err != nil {
+		log.Fatalf("Error while filtering CFP proposals: %v", err)
+	}
+	return event
+}
+
+func runPublish(organizationName, trelloKey, trelloSecret, eventID, cfpKey string, cfpVersion cfp.APIVersion, pub publisher.Publication, dryRun bool, opts ...publisher.Option) {
 	requireArg(organizationName, "org")
 	requireArg(trelloKey, "trello-key")
 	requireArg(trelloSecret, "trello-secret")
@@ -84,14 +356,209 @@ func runPublish(organizationName, trelloKey, trelloSecret, eventID, cfpKey strin
 		cfp.WithURL(cfp.URL),
 		cfp.WithEventID(eventID),
 		cfp.WithAPIKey(cfpKey),
+		cfp.WithAPIVersion(cfpVersion),
 		cfp.WithDryRun(dryRun),
 	)
 
-	if err := publisher.Publish(organizationName, cfpClient, trelloClient, pub); err != nil {
+	result, err := publisher.Publish(organizationName, cfpClient, trelloClient, pub, opts...)
+	if len(result.Entries) > 0 {
+		if err := result.Print(os.Stdout); err != nil {
+			log.Printf("Error while printing publication result: %v", err)
+		}
+	}
+	if err != nil {
 		log.Fatalf("Error while publishing to Conference-Hall: %v", err)
 	}
 }
 
+func runValidate(organizationName, trelloKey, trelloSecret, eventID, cfpKey string, cfpVersion cfp.APIVersion, boardLayout layout.Layout, rules publisher.Rules) {
+	requireArg(organizationName, "org")
+	requireArg(trelloKey, "trello-key")
+	requireArg(trelloSecret, "trello-secret")
+	requireArg(eventID, "event-id")
+	requireArg(cfpKey, "cfp-key")
+
+	trelloClient, err := trello.New(trelloKey, trelloSecret)
+	if err != nil {
+		log.Fatalf("Error while creating Trello Client: %v", err)
+	}
+
+	cfpClient := cfp.NewConferenceHallClient(
+		cfp.WithURL(cfp.URL),
+		cfp.WithEventID(eventID),
+		cfp.WithAPIKey(cfpKey),
+		cfp.WithAPIVersion(cfpVersion),
+	)
+
+	findings, err := publisher.Validate(organizationName, cfpClient, trelloClient, rules, publisher.WithLayout(boardLayout))
+	if err != nil {
+		log.Fatalf("Error while validating Trello boards: %v", err)
+	}
+	if len(findings) == 0 {
+		log.Println("No issue found")
+		return
+	}
+	if err := findings.Print(os.Stdout); err != nil {
+		log.Printf("Error while printing findings: %v", err)
+	}
+	if findings.HasErrors() {
+		os.Exit(1)
+	}
+}
+
+func runSyncBack(organizationName, trelloKey, trelloSecret, eventID, cfpKey string, cfpVersion cfp.APIVersion, boardLayout layout.Layout) {
+	requireArg(organizationName, "org")
+	requireArg(trelloKey, "trello-key")
+	requireArg(trelloSecret, "trello-secret")
+	requireArg(eventID, "event-id")
+	requireArg(cfpKey, "cfp-key")
+
+	trelloClient, err := trello.New(trelloKey, trelloSecret)
+	if err != nil {
+		log.Fatalf("Error while creating Trello Client: %v", err)
+	}
+
+	cfpClient := cfp.NewConferenceHallClient(
+		cfp.WithURL(cfp.URL),
+		cfp.WithEventID(eventID),
+		cfp.WithAPIKey(cfpKey),
+		cfp.WithAPIVersion(cfpVersion),
+	)
+
+	if err := publisher.SyncBack(organizationName, cfpClient, trelloClient, publisher.WithLayout(boardLayout)); err != nil {
+		log.Fatalf("Error while syncing Conference-Hall states back to Trello: %v", err)
+	}
+}
+
+func runCleanup(organizationName, trelloKey, trelloSecret string, source cfp.Source, boardLayout layout.Layout, deleteBoards bool) {
+	requireArg(organizationName, "org")
+	requireArg(trelloKey, "trello-key")
+	requireArg(trelloSecret, "trello-secret")
+
+	// only the formats of the event are needed
+	event, err := source.Event(geo.NoLocate)
+	if err != nil {
+		log.Fatalf("Error while loading CFP: %v", err)
+	}
+
+	client, err := trello.New(trelloKey, trelloSecret)
+	if err != nil {
+		log.Fatalf("Error while creating Trello Client: %v", err)
+	}
+
+	if err := cleanup.Cleanup(organizationName, event, client, confirm, cleanup.WithLayout(boardLayout), cleanup.WithDelete(deleteBoards)); err != nil {
+		log.Fatalf("Error while cleaning up Trello boards: %v", err)
+	}
+}
+
+// confirm asks the user to confirm on the standard input.
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N] ", prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// newSource returns the source of the CFP: the JSON export file if given, or Conference-Hall API otherwise.
+// Sessionize and pretalx exports are only read from files, the event name being missing from them.
+func newSource(sourceName, eventID, eventName, jsonPath, cfpKey string, cfpVersion cfp.APIVersion) cfp.Source {
+	switch sourceName {
+	case "conference-hall":
+		if jsonPath != "" {
+			return cfp.ConferenceHallFile{Path: jsonPath, Version: cfpVersion}
+		}
+		requireArg(eventID, "event-id")
+		requireArg(cfpKey, "json or cfp-key")
+		return cfp.NewConferenceHallClient(
+			cfp.WithURL(cfp.URL),
+			cfp.WithEventID(eventID),
+			cfp.WithAPIKey(cfpKey),
+			cfp.WithAPIVersion(cfpVersion),
+		)
+	case "sessionize":
+		requireArg(jsonPath, "json")
+		requireArg(eventName, "event-name")
+		return cfp.SessionizeFile{Path: jsonPath, EventName: eventName}
+	case "pretalx":
+		requireArg(jsonPath, "json")
+		requireArg(eventName, "event-name")
+		return cfp.PretalxFile{Path: jsonPath, EventName: eventName}
+	default:
+		log.Fatalf("Unknown CFP source %s", sourceName)
+		return nil
+	}
+}
+
+// isFlagSet returns true if the flag was given on the command line, rather than left to its default value.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// splitList splits a comma separated list of values.
+func splitList(s string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// parseRules returns the validation rules, quotas being given as format=slots.
+func parseRules(maxTalksPerSpeaker int, quotas string) (publisher.Rules, error) {
+	rules := publisher.Rules{MaxTalksPerSpeaker: maxTalksPerSpeaker, Quotas: make(map[string]int)}
+	for _, quota := range splitList(quotas) {
+		format, slots, ok := strings.Cut(quota, "=")
+		if !ok {
+			return publisher.Rules{}, fmt.Errorf("quota %q must be format=slots", quota)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(slots))
+		if err != nil || n < 0 {
+			return publisher.Rules{}, fmt.Errorf("invalid slots of quota %q", quota)
+		}
+		rules.Quotas[strings.TrimSpace(format)] = n
+	}
+	return rules, nil
+}
+
+// tieringFlag collects the tierings given on the command line, either for all formats or for a specific one.
+type tieringFlag struct {
+	values  []string
+	options []importer.Option
+}
+
+func (f *tieringFlag) String() string {
+	return strings.Join(f.values, " ")
+}
+
+func (f *tieringFlag) Set(value string) error {
+	format, spec, specific := strings.Cut(value, "=")
+	if !specific {
+		spec = format
+	}
+	tiering, err := importer.ParseTiering(spec)
+	if err != nil {
+		return err
+	}
+	if specific {
+		f.options = append(f.options, importer.WithFormatTiering(format, tiering))
+	} else {
+		f.options = append(f.options, importer.WithTiering(tiering))
+	}
+	f.values = append(f.values, value)
+	return nil
+}
+
 func requireArg(value, name string) {
 	if value != "" {
 		return
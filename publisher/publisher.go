@@ -3,99 +3,502 @@ package publisher
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/bdxio/cfp-to-trello/cfp"
+	"github.com/bdxio/cfp-to-trello/layout"
 	"github.com/bdxio/cfp-to-trello/trello"
 )
 
 type Publication string
 
 const (
+	// PublicationAccept accepts the talks of the selection list.
 	PublicationAccept Publication = "accept"
+	// PublicationAcceptBackups accepts the talks of the accepted backups list, once backups are promoted.
+	PublicationAcceptBackups Publication = "accept-backups"
+	// PublicationReject rejects the talks of the rejections list.
 	PublicationReject Publication = "reject"
+	// PublicationRejectRemaining rejects the talks of the boards' formats which are neither selected, nor backups,
+	// nor withdrawn.
+	PublicationRejectRemaining Publication = "reject-remaining"
 )
 
-func Publish(orgName string, cfpClient cfp.ConferenceHallClient, trelloClient trello.Client, pub Publication) error {
-	export, err := cfpClient.GetExport()
+// role returns the role of the list holding the talks to publish.
+func (pub Publication) role() layout.Role {
+	switch pub {
+	case PublicationAcceptBackups:
+		return layout.RoleAcceptedBackups
+	case PublicationReject:
+		return layout.RoleRejections
+	default:
+		return layout.RoleSelection
+	}
+}
+
+// verb returns the action done on the talks.
+func (pub Publication) verb() string {
+	if pub.accept() {
+		return "accept"
+	}
+	return "reject"
+}
+
+// accept returns true if the talks are accepted, false if they are rejected.
+func (pub Publication) accept() bool {
+	return pub != PublicationReject && pub != PublicationRejectRemaining
+}
+
+// Confirm asks for the confirmation of a publication.
+type Confirm func(prompt string) bool
+
+type Option func(p *publisher)
+
+// WithLayout sets the layout of the deliberation boards.
+func WithLayout(l layout.Layout) Option {
+	return func(p *publisher) {
+		p.layout = l
+	}
+}
+
+// WithContinueOnError keeps publishing when a card can't be matched to a talk or a talk can't be published.
+// Failures are reported in the result.
+func WithContinueOnError(continueOnError bool) Option {
+	return func(p *publisher) {
+		p.continueOnError = continueOnError
+	}
+}
+
+// WithConfirm logs the talks to publish grouped by board, and asks for a confirmation before publishing them.
+func WithConfirm(confirm Confirm) Option {
+	return func(p *publisher) {
+		p.confirm = confirm
+	}
+}
+
+// WithForce rejects the remaining talks even if tier lists still have cards.
+func WithForce(force bool) Option {
+	return func(p *publisher) {
+		p.force = force
+	}
+}
+
+// WithComment comments the cards of the talks once published, true by default.
+func WithComment(comment bool) Option {
+	return func(p *publisher) {
+		p.comment = comment
+	}
+}
+
+// WithPublishedLabel puts a label on the cards of the talks once published.
+// Cards having this label are skipped by later publications.
+func WithPublishedLabel(label bool) Option {
+	return func(p *publisher) {
+		p.publishedLabel = label
+	}
+}
+
+// WithReport writes a report of the publication to w once done, even if it failed.
+func WithReport(w io.Writer, format ReportFormat) Option {
+	return func(p *publisher) {
+		p.report = w
+		p.reportFormat = format
+	}
+}
+
+type publisher struct {
+	layout          layout.Layout
+	continueOnError bool
+	confirm         Confirm
+	force           bool
+	report          io.Writer
+	reportFormat    ReportFormat
+	comment         bool
+	publishedLabel  bool
+	now             func() time.Time
+}
+
+func newPublisher(opts ...Option) publisher {
+	p := publisher{layout: layout.Default(), comment: true, now: time.Now}
+	for _, opt := range opts {
+		opt(&p)
+	}
+	return p
+}
+
+// Publish accepts or rejects in Conference-Hall the talks of the deliberation boards.
+// The result holds the outcome of every processed card, even when an error is returned.
+func Publish(orgName string, cfpClient cfp.ConferenceHallClient, trelloClient trello.Client, pub Publication, opts ...Option) (Result, error) {
+	p := newPublisher(opts...)
+
+	var result Result
+	err := p.publish(orgName, cfpClient, trelloClient, pub, &result)
+	if err == nil && result.Failures() > 0 {
+		err = fmt.Errorf("%d cards could not be published", result.Failures())
+	}
+	if p.report != nil {
+		if reportErr := result.WriteReport(p.report, p.reportFormat); reportErr != nil && err == nil {
+			err = fmt.Errorf("error while writing publication report: %w", reportErr)
+		}
+	}
+	return result, err
+}
+
+func (p publisher) publish(orgName string, cfpClient cfp.ConferenceHallClient, trelloClient trello.Client, pub Publication, result *Result) error {
+	planned, err := p.plan(orgName, cfpClient, trelloClient, pub, result)
 	if err != nil {
 		return err
 	}
 
+	if p.confirm != nil {
+		count := logPlan(planned, pub)
+		if count == 0 {
+			log.Printf("No talk to %s\n", pub.verb())
+		} else if !p.confirm(fmt.Sprintf("Do you want to %s these %d talks?", pub.verb(), count)) {
+			log.Println("Publication cancelled")
+			return nil
+		}
+	}
+
+	for _, entry := range planned {
+		if entry.Outcome != outcomePlanned {
+			entry.Time = p.now()
+			result.add(entry)
+			continue
+		}
+		if pub.accept() {
+			log.Printf("Accepting talk %s...", entry.Talk.Title)
+			entry.Response, entry.Err = cfpClient.Accept(entry.Talk)
+			entry.Outcome = OutcomeAccepted
+		} else {
+			log.Printf("Rejecting talk %s...", entry.Talk.Title)
+			entry.Response, entry.Err = cfpClient.Reject(entry.Talk)
+			entry.Outcome = OutcomeRejected
+		}
+		entry.Time = p.now()
+		if entry.Err != nil {
+			entry.Outcome = OutcomeFailed
+			if err := p.fail(result, entry); err != nil {
+				return err
+			}
+			continue
+		}
+		log.Printf("%s\n", entry.Response)
+		p.mark(trelloClient, entry)
+		result.add(entry)
+	}
+	return nil
+}
+
+const publishedLabel = "Publié"
+
+// mark comments the card of a published talk and labels it.
+// The talk being published anyway, failures are only logged.
+func (p publisher) mark(client trello.Client, entry Entry) {
+	if entry.card.ID == "" {
+		return
+	}
+	if p.comment {
+		text := fmt.Sprintf("✅ Accepté dans Conference-Hall %s", stamp(p.now()))
+		if entry.Outcome == OutcomeRejected {
+			text = fmt.Sprintf("❌ Refusé dans Conference-Hall %s", stamp(p.now()))
+		}
+		if err := client.CreateComment(text, entry.card); err != nil {
+			log.Printf("⚠️ Error while commenting card %s: %v\n", entry.Card, err)
+		}
+	}
+	if p.publishedLabel {
+		label, err := client.CreateLabel(publishedLabel, entry.board, trello.ColorBlue)
+		if err == nil {
+			err = client.AddLabel(entry.card, label)
+		}
+		if err != nil {
+			log.Printf("⚠️ Error while labelling card %s: %v\n", entry.Card, err)
+		}
+	}
+}
+
+// plan returns the entries of all cards of the boards, in order.
+// Talks to publish are planned, the others are withdrawn, skipped or unmatched.
+func (p publisher) plan(orgName string, cfpClient cfp.ConferenceHallClient, trelloClient trello.Client, pub Publication, result *Result) ([]Entry, error) {
+	export, err := cfpClient.GetExport()
+	if err != nil {
+		return nil, err
+	}
+
 	organization, err := trelloClient.GetOrganization(orgName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	boards, err := trelloClient.GetBoards(organization, trello.PermissionLevelOrg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	boards = filterTrelloBoards(boards, export.Name, export.Formats)
+	formats := getFormats(export.Formats)
+	boards = p.layout.FilterBoards(boards, export.Name, formats)
 	if len(boards) == 0 {
-		return errors.New("no board for CFP found in Trello")
+		return nil, errors.New("no board for CFP found in Trello")
+	}
+	boardFormats := make(map[string]string, len(formats))
+	for _, format := range formats {
+		boardFormats[p.layout.BoardNameFor(export.Name, format)] = format
+	}
+
+	pl := planner{publisher: p, client: trelloClient, index: newTalkIndex(export), result: result}
+	if pub == PublicationRejectRemaining {
+		if err := pl.planRemaining(boards, boardFormats, export.Talks); err != nil {
+			return nil, err
+		}
+		return pl.entries, nil
 	}
 	for _, board := range boards {
 		lists, err := trelloClient.GetLists(board)
+		if err != nil {
+			return nil, err
+		}
+		if err := pl.planList(board, lists, pub.role()); err != nil {
+			return nil, err
+		}
+	}
+	return pl.entries, nil
+}
+
+type planner struct {
+	publisher
+	client  trello.Client
+	index   talkIndex
+	result  *Result
+	entries []Entry
+}
+
+// planList plans the talks of the list with the given role, except the withdrawn ones.
+func (pl *planner) planList(board trello.Board, lists []trello.List, role layout.Role) error {
+	withdrawn, err := pl.planWithdrawals(board, lists)
+	if err != nil {
+		return err
+	}
+
+	name, ok := pl.layout.ListName(role)
+	if !ok {
+		return fmt.Errorf("no list with role %s in layout", role)
+	}
+	list, ok := getList(lists, name)
+	if !ok {
+		return fmt.Errorf("list %s not found for board %s", name, board.Name)
+	}
+	return pl.matchCards(board, list, func(card trello.Card, talk cfp.Talk) error {
+		if _, ok := withdrawn[talk.ID]; ok {
+			return nil
+		}
+		return pl.planTalk(board, card, talk)
+	})
+}
+
+// planRemaining plans the talks of the formats of the boards which are neither selected, nor backups, nor withdrawn
+// in any board.
+func (pl *planner) planRemaining(boards []trello.Board, boardFormats map[string]string, talks []cfp.Talk) error {
+	kept := make(map[string]struct{})
+	cards := make(map[string]trello.Card) // by talk ID, so that rejected talks having a card are marked
+	for _, board := range boards {
+		lists, err := pl.client.GetLists(board)
 		if err != nil {
 			return err
 		}
-		name := trello.ListSelection
-		if pub == PublicationReject {
-			name = trello.ListRefuses
+		if err := pl.keep(board, lists, kept, cards); err != nil {
+			return err
+		}
+	}
+
+	for _, board := range boards {
+		for _, talk := range talks {
+			if pl.index.formats[talk.Formats] != boardFormats[board.Name] || !talk.IsSubmitted() {
+				continue
+			}
+			if _, ok := kept[talk.ID]; ok {
+				continue
+			}
+			if err := pl.planTalk(board, cards[talk.ID], talk); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// keep adds to kept the talks of a board that must not be rejected: withdrawn, selected and backup talks.
+// Talks must have been moved out of the tier lists first, unless forced. The cards of the other lists are added to
+// cards.
+func (pl *planner) keep(board trello.Board, lists []trello.List, kept map[string]struct{}, cards map[string]trello.Card) error {
+	withdrawn, err := pl.planWithdrawals(board, lists)
+	if err != nil {
+		return err
+	}
+	for id := range withdrawn {
+		kept[id] = struct{}{}
+	}
+	for _, role := range []layout.Role{layout.RoleSelection, layout.RoleAcceptedBackups, layout.RoleBackups} {
+		name, ok := pl.layout.ListName(role)
+		if !ok {
+			continue
 		}
 		list, ok := getList(lists, name)
 		if !ok {
-			return fmt.Errorf("list %s not found for board %s", name, board.Name)
+			continue
 		}
-		cards, err := trelloClient.GetCards(list)
+		err := pl.matchCards(board, list, func(_ trello.Card, talk cfp.Talk) error {
+			kept[talk.ID] = struct{}{}
+			return nil
+		})
 		if err != nil {
 			return err
 		}
-		talks, err := getTalks(cards, export.Talks)
+	}
+
+	tierCards := 0
+	for _, list := range lists {
+		listCards, err := pl.client.GetCards(list)
 		if err != nil {
 			return err
 		}
-		for _, talk := range talks {
-			if !talk.IsSubmitted() {
-				log.Printf("Talk is already %s\n", talk.State)
-				continue
-			}
-			log.Printf("%sing talk %s...", pub, talk.Title)
-			switch pub {
-			case PublicationAccept:
-				resp, err := cfpClient.Accept(talk)
-				if err != nil {
-					return err
+		for _, card := range listCards {
+			// cards not matching any talk are left to the validation
+			if talk, err := pl.index.match(card); err == nil {
+				if _, ok := cards[talk.ID]; !ok {
+					cards[talk.ID] = card
 				}
-				log.Printf("%s\n", resp)
-			case PublicationReject:
-				resp, err := cfpClient.Reject(talk)
-				if err != nil {
-					return err
-				}
-				log.Printf("%s\n", resp)
 			}
 		}
+		if pl.layout.IsTierList(list.Name) {
+			tierCards += len(listCards)
+		}
+	}
+	if tierCards > 0 {
+		if !pl.force {
+			return fmt.Errorf("tier lists of board %s still have %d cards, move them first or force the rejection", board.Name, tierCards)
+		}
+		log.Printf("⚠️ Tier lists of board %s still have %d cards, rejecting them anyway\n", board.Name, tierCards)
 	}
 	return nil
 }
 
-func filterTrelloBoards(boards []trello.Board, eventName string, formats []cfp.Format) []trello.Board {
-	boardNames := make(map[string]struct{})
-	for _, format := range formats {
-		boardName := fmt.Sprintf("Délibération %s - %s", eventName, format.Name)
-		boardNames[boardName] = struct{}{}
+// planWithdrawals reports the talks whose speakers withdrew, they are left untouched.
+// It returns the IDs of the withdrawn talks.
+func (pl *planner) planWithdrawals(board trello.Board, lists []trello.List) (map[string]struct{}, error) {
+	withdrawn := make(map[string]struct{})
+	name, ok := pl.layout.ListName(layout.RoleWithdrawals)
+	if !ok {
+		return withdrawn, nil
+	}
+	list, ok := getList(lists, name)
+	if !ok {
+		return withdrawn, nil
+	}
+	err := pl.matchCards(board, list, func(card trello.Card, talk cfp.Talk) error {
+		log.Printf("Speakers of talk %s withdrew, leaving it %s\n", talk.Title, talk.State)
+		withdrawn[talk.ID] = struct{}{}
+		entry := pl.index.entry(board, card, talk)
+		entry.Outcome = OutcomeWithdrawn
+		pl.entries = append(pl.entries, entry)
+		return nil
+	})
+	return withdrawn, err
+}
+
+// planTalk plans the publication of a talk, unless it is not submitted anymore or its card is labelled as published.
+func (pl *planner) planTalk(board trello.Board, card trello.Card, talk cfp.Talk) error {
+	entry := pl.index.entry(board, card, talk)
+	entry.Outcome = outcomePlanned
+	if !talk.IsSubmitted() {
+		log.Printf("Talk %s is already %s\n", talk.Title, talk.State)
+		entry.Outcome = OutcomeSkipped
+		entry.SkipReason = "already " + talk.State
+	} else if pl.publishedLabel && card.ID != "" {
+		// the label is only created once publishing is confirmed
+		label, ok, err := pl.client.FindLabel(publishedLabel, board)
+		if err != nil {
+			return err
+		}
+		if ok && card.HasLabel(label) {
+			log.Printf("Talk %s is already published\n", talk.Title)
+			entry.Outcome = OutcomeSkipped
+			entry.SkipReason = "already published"
+		}
+	}
+	pl.entries = append(pl.entries, entry)
+	return nil
+}
+
+// matchCards calls f for every card of the list matching a talk.
+// Unmatched cards are reported, and stop the planning unless publishing continues on errors.
+func (pl *planner) matchCards(board trello.Board, list trello.List, f func(card trello.Card, talk cfp.Talk) error) error {
+	cards, err := pl.client.GetCards(list)
+	if err != nil {
+		return err
+	}
+	for _, card := range cards {
+		talk, err := pl.index.match(card)
+		if err != nil {
+			entry := Entry{Board: board.Name, Card: card.Name, Outcome: OutcomeUnmatched, Err: err, Time: pl.now()}
+			if !pl.continueOnError {
+				pl.result.add(entry)
+				return err
+			}
+			log.Printf("⚠️ %v\n", err)
+			pl.entries = append(pl.entries, entry)
+			continue
+		}
+		if err := f(card, talk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logPlan logs the talks to publish grouped by board, and returns their count.
+func logPlan(planned []Entry, pub Publication) int {
+	var boards []string
+	talks := make(map[string][]string)
+	for _, entry := range planned {
+		if entry.Outcome != outcomePlanned {
+			continue
+		}
+		if _, ok := talks[entry.Board]; !ok {
+			boards = append(boards, entry.Board)
+		}
+		talks[entry.Board] = append(talks[entry.Board], entry.Talk.Title)
 	}
-	cfpBoards := make([]trello.Board, 0)
+	count := 0
 	for _, board := range boards {
-		if _, ok := boardNames[board.Name]; ok {
-			cfpBoards = append(cfpBoards, board)
+		log.Printf("Board %s: %d talks to %s\n", board, len(talks[board]), pub.verb())
+		for _, title := range talks[board] {
+			log.Printf("  - %s\n", title)
 		}
+		count += len(talks[board])
 	}
-	return cfpBoards
+	return count
+}
+
+// fail records a failure, and returns its cause unless publishing should go on.
+func (p publisher) fail(result *Result, entry Entry) error {
+	result.add(entry)
+	if p.continueOnError {
+		log.Printf("⚠️ %v\n", entry.Err)
+		return nil
+	}
+	return entry.Err
+}
+
+func getFormats(formats []cfp.Format) []string {
+	names := make([]string, 0, len(formats))
+	for _, format := range formats {
+		names = append(names, format.Name)
+	}
+	return names
 }
 
 func getList(lists []trello.List, name string) (list trello.List, ok bool) {
@@ -107,20 +510,75 @@ func getList(lists []trello.List, name string) (list trello.List, ok bool) {
 	return
 }
 
-func getTalks(cards []trello.Card, talks []cfp.Talk) (matched []cfp.Talk, err error) {
-	talksByTitle := make(map[string]cfp.Talk, len(talks))
-	for _, talk := range talks {
+// talkIndex matches cards to talks.
+type talkIndex struct {
+	byID       map[string]cfp.Talk
+	byTitle    map[string][]cfp.Talk
+	formats    map[string]string
+	categories map[string]string
+	speakers   map[string]string
+}
+
+func newTalkIndex(export cfp.Export) talkIndex {
+	index := talkIndex{
+		byID:       make(map[string]cfp.Talk, len(export.Talks)),
+		byTitle:    make(map[string][]cfp.Talk, len(export.Talks)),
+		formats:    make(map[string]string, len(export.Formats)),
+		categories: make(map[string]string, len(export.Categories)),
+		speakers:   make(map[string]string, len(export.Speakers)),
+	}
+	for _, format := range export.Formats {
+		index.formats[format.ID] = format.Name
+	}
+	for _, category := range export.Categories {
+		index.categories[category.ID] = category.Name
+	}
+	for _, speaker := range export.Speakers {
+		index.speakers[speaker.UID] = speaker.DisplayName
+	}
+	for _, talk := range export.Talks {
+		index.byID[talk.ID] = talk
 		title := strings.Trim(talk.Title, " ")
-		talksByTitle[title] = talk
+		index.byTitle[title] = append(index.byTitle[title], talk)
 	}
+	return index
+}
 
-	for _, card := range cards {
-		if talk, ok := talksByTitle[card.Name]; ok {
-			matched = append(matched, talk)
-			continue
+// match returns the talk of a card, identified by the proposal ID found in its description.
+// Cards without proposal ID are matched by title, as long as it is not shared by several talks.
+func (idx talkIndex) match(card trello.Card) (cfp.Talk, error) {
+	if id, ok := cfp.FindProposalID(card.Desc); ok {
+		talk, ok := idx.byID[id]
+		if !ok {
+			return cfp.Talk{}, fmt.Errorf("talk %s of card %q not found in CFP talks", id, card.Name)
 		}
-		return matched, fmt.Errorf("talk %q not found in CFP talks", card.Name)
+		return talk, nil
+	}
+	switch t := idx.byTitle[card.Name]; len(t) {
+	case 0:
+		return cfp.Talk{}, fmt.Errorf("talk %q not found in CFP talks", card.Name)
+	case 1:
+		log.Printf("⚠️ No proposal ID found for card %q, matched talk %s by title\n", card.Name, t[0].ID)
+		return t[0], nil
+	default:
+		return cfp.Talk{}, fmt.Errorf("no proposal ID found for card %q and several talks have this title", card.Name)
 	}
-	return
 }
 
+// entry returns the entry of a matched card, with the names of the format, category and speakers of its talk.
+func (idx talkIndex) entry(board trello.Board, card trello.Card, talk cfp.Talk) Entry {
+	speakers := make([]string, 0, len(talk.Speakers))
+	for _, uid := range talk.Speakers {
+		speakers = append(speakers, idx.speakers[uid])
+	}
+	return Entry{
+		Board:    board.Name,
+		Card:     card.Name,
+		Talk:     talk,
+		Format:   idx.formats[talk.Formats],
+		Category: idx.categories[talk.Categories],
+		Speakers: speakers,
+		board:    board,
+		card:     card,
+	}
+}
@@ -1,7 +1,13 @@
 package publisher
 
 import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -12,19 +18,12 @@ import (
 
 func TestPublish_Accept(t *testing.T) {
 	// setup Conference Hall
-	srv, stop := cfp.NewConferenceHallServer("12345", "67890", "../cfp/testdata/export.json")
-	t.Cleanup(stop)
-	cfpClient := cfp.NewConferenceHallClient(
-		cfp.WithURL(srv.URL),
-		cfp.WithEventID("12345"),
-		cfp.WithAPIKey("67890"),
-		cfp.WithHTTPClient(srv.Client),
-	)
+	srv, cfpClient := newCFPClient(t, "../cfp/testdata/export.json")
 
 	// setup Trello
 	trelloClient := setupTrello(t)
 
-	err := Publish("test", cfpClient, trelloClient, PublicationAccept)
+	_, err := Publish("test", cfpClient, trelloClient, PublicationAccept)
 	require.NoError(t, err)
 
 	assert.Equal(t, []string{"6grkSZ4ArcYr8BZfcw0o", "dghzra8K2TfMYnBDjUEb"}, srv.AcceptedIDs)
@@ -33,25 +32,328 @@ func TestPublish_Accept(t *testing.T) {
 
 func TestPublish_Reject(t *testing.T) {
 	// setup Conference Hall
-	srv, stop := cfp.NewConferenceHallServer("12345", "67890", "../cfp/testdata/export.json")
-	t.Cleanup(stop)
-	cfpClient := cfp.NewConferenceHallClient(
-		cfp.WithURL(srv.URL),
-		cfp.WithEventID("12345"),
-		cfp.WithAPIKey("67890"),
-		cfp.WithHTTPClient(srv.Client),
-	)
+	srv, cfpClient := newCFPClient(t, "../cfp/testdata/export.json")
 
 	// setup Trello
 	trelloClient := setupTrello(t)
 
-	err := Publish("test", cfpClient, trelloClient, PublicationReject)
+	_, err := Publish("test", cfpClient, trelloClient, PublicationReject)
 	require.NoError(t, err)
 
 	assert.Equal(t, []string{"Hj2ZNh7ydvOnpg9TBHeL", "xdUotyrnjlJ0XiIUZasR"}, srv.RejectedIDs)
 	assert.Empty(t, srv.AcceptedIDs)
 }
 
+func TestPublish_MarkCards(t *testing.T) {
+	srv, cfpClient := newCFPClient(t, "../cfp/testdata/export.json")
+	trelloClient := setupTrello(t).(trello.FakeClient)
+	now := func(p *publisher) {
+		p.now = func() time.Time { return time.Date(2042, 10, 16, 14, 2, 0, 0, time.Local) }
+	}
+
+	_, err := Publish("test", cfpClient, trelloClient, PublicationAccept, WithPublishedLabel(true), now)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"6grkSZ4ArcYr8BZfcw0o", "dghzra8K2TfMYnBDjUEb"}, srv.AcceptedIDs)
+	assert.Equal(t, []string{"✅ Accepté dans Conference-Hall le 16/10 à 14h02"}, trelloClient.Comments["A beginner talk in category 1"])
+	assert.Equal(t, []string{"✅ Accepté dans Conference-Hall le 16/10 à 14h02"}, trelloClient.Comments["Another talk in category 2"])
+	assert.Empty(t, trelloClient.Comments["Another beginner talk in category 1"])
+	assert.Equal(t, []string{"Publié"}, trelloClient.Cards["A beginner talk in category 1"].IDLabels)
+
+	// cards already published are skipped
+	result, err := Publish("test", cfpClient, trelloClient, PublicationAccept, WithPublishedLabel(true), now)
+	require.NoError(t, err)
+	assert.Len(t, srv.AcceptedIDs, 2)
+	assert.Equal(t, 3, result.Count(OutcomeSkipped))
+	reasons := make(map[string]string)
+	for _, entry := range result.Entries {
+		reasons[entry.Card] = entry.SkipReason
+		assert.Empty(t, entry.Response)
+	}
+	assert.Equal(t, "already published", reasons["A beginner talk in category 1"])
+	assert.Equal(t, "already accepted", reasons["Another beginner talk in category 1"])
+	assert.Len(t, trelloClient.Comments["A beginner talk in category 1"], 1)
+}
+
+func TestPublish_MarkCards_Cancelled(t *testing.T) {
+	srv, cfpClient := newCFPClient(t, "../cfp/testdata/export.json")
+	trelloClient := setupTrello(t).(trello.FakeClient)
+
+	_, err := Publish("test", cfpClient, trelloClient, PublicationAccept, WithPublishedLabel(true), WithConfirm(func(string) bool { return false }))
+
+	require.NoError(t, err)
+	assert.Empty(t, srv.AcceptedIDs)
+	assert.NotContains(t, trelloClient.Labels, "Publié")
+}
+
+func TestPublish_RejectRemaining(t *testing.T) {
+	srv, cfpClient := newCFPClient(t, "../cfp/testdata/export.json")
+	trelloClient := setupTrelloPublications(t)
+
+	_, err := Publish("test", cfpClient, trelloClient, PublicationRejectRemaining)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Hj2ZNh7ydvOnpg9TBHeL", "xdUotyrnjlJ0XiIUZasR"}, srv.RejectedIDs)
+	assert.Empty(t, srv.AcceptedIDs)
+}
+
+func TestPublish_RejectRemaining_TierListsNotEmpty(t *testing.T) {
+	srv, cfpClient := newCFPClient(t, "../cfp/testdata/export.json")
+	trelloClient := setupTrelloPublications(t).(trello.FakeClient)
+	tier, err := trelloClient.CreateList("T1", trello.Board{ID: "Délibération Awesome Conference 2042 - Format 1"})
+	require.NoError(t, err)
+	require.NoError(t, trelloClient.MoveCard(trelloClient.Cards["A talk in category 2"], tier))
+	now := func(p *publisher) {
+		p.now = func() time.Time { return time.Date(2042, 10, 16, 14, 2, 0, 0, time.Local) }
+	}
+
+	_, err = Publish("test", cfpClient, trelloClient, PublicationRejectRemaining)
+	assert.EqualError(t, err, "tier lists of board Délibération Awesome Conference 2042 - Format 1 still have 1 cards, move them first or force the rejection")
+	assert.Empty(t, srv.RejectedIDs)
+
+	_, err = Publish("test", cfpClient, trelloClient, PublicationRejectRemaining, WithForce(true), WithComment(true), now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Hj2ZNh7ydvOnpg9TBHeL", "tzdLHxKDtVUXcJLd66TN", "xdUotyrnjlJ0XiIUZasR"}, srv.RejectedIDs)
+	assert.Equal(t, []string{"❌ Refusé dans Conference-Hall le 16/10 à 14h02"}, trelloClient.Comments["A talk in category 2"])
+}
+
+func TestPublish_AcceptBackups(t *testing.T) {
+	// setup Conference Hall
+	srv, cfpClient := newCFPClient(t, "../cfp/testdata/export.json")
+
+	// setup Trello
+	trelloClient := setupTrelloPublications(t)
+
+	_, err := Publish("test", cfpClient, trelloClient, PublicationAcceptBackups)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"bSKbIciG4jCWk37vrTEp"}, srv.AcceptedIDs)
+	assert.Empty(t, srv.RejectedIDs)
+}
+
+func TestPublish_StopOnError(t *testing.T) {
+	srv, cfpClient := newCFPClient(t, "../cfp/testdata/export.json")
+	srv.FailingIDs = map[string]bool{"6grkSZ4ArcYr8BZfcw0o": true}
+	trelloClient := setupTrello(t)
+
+	result, err := Publish("test", cfpClient, trelloClient, PublicationAccept)
+
+	assert.EqualError(t, err, "error while accepting talk A beginner talk in category 1: 500")
+	assert.Empty(t, srv.AcceptedIDs)
+	require.Len(t, result.Entries, 1)
+	assert.Equal(t, OutcomeFailed, result.Entries[0].Outcome)
+}
+
+func TestPublish_ContinueOnError(t *testing.T) {
+	srv, cfpClient := newCFPClient(t, "../cfp/testdata/export.json")
+	srv.FailingIDs = map[string]bool{"6grkSZ4ArcYr8BZfcw0o": true}
+	trelloClient := setupTrelloPublications(t)
+
+	result, err := Publish("test", cfpClient, trelloClient, PublicationAccept, WithContinueOnError(true))
+
+	assert.EqualError(t, err, "1 cards could not be published")
+	assert.Equal(t, []string{"dghzra8K2TfMYnBDjUEb"}, srv.AcceptedIDs)
+	assert.Equal(t, 1, result.Count(OutcomeFailed))
+	assert.Equal(t, 1, result.Count(OutcomeAccepted))
+	assert.Equal(t, 1, result.Count(OutcomeSkipped))
+	assert.Equal(t, 1, result.Count(OutcomeWithdrawn))
+
+	var out strings.Builder
+	require.NoError(t, result.Print(&out))
+	assert.Contains(t, out.String(), "A beginner talk in category 1")
+	assert.Contains(t, out.String(), "1 accepted, 0 rejected, 1 skipped, 1 withdrawn, 0 unmatched, 1 failed")
+}
+
+func TestPublish_Confirm(t *testing.T) {
+	srv, cfpClient := newCFPClient(t, "../cfp/testdata/export.json")
+	trelloClient := setupTrello(t)
+	var prompt string
+
+	result, err := Publish("test", cfpClient, trelloClient, PublicationReject, WithConfirm(func(p string) bool {
+		prompt = p
+		return true
+	}))
+
+	require.NoError(t, err)
+	assert.Equal(t, "Do you want to reject these 2 talks?", prompt)
+	assert.Equal(t, []string{"Hj2ZNh7ydvOnpg9TBHeL", "xdUotyrnjlJ0XiIUZasR"}, srv.RejectedIDs)
+	assert.Equal(t, 2, result.Count(OutcomeRejected))
+}
+
+func TestPublish_Cancelled(t *testing.T) {
+	srv, cfpClient := newCFPClient(t, "../cfp/testdata/export.json")
+	trelloClient := setupTrello(t)
+
+	result, err := Publish("test", cfpClient, trelloClient, PublicationReject, WithConfirm(func(string) bool { return false }))
+
+	require.NoError(t, err)
+	assert.Empty(t, srv.RejectedIDs)
+	assert.Empty(t, result.Entries)
+}
+
+func TestPublish_Report(t *testing.T) {
+	_, cfpClient := newCFPClient(t, "../cfp/testdata/export.json")
+	trelloClient := setupTrelloPublications(t)
+	var report strings.Builder
+	now := func(p *publisher) {
+		p.now = func() time.Time { return time.Date(2042, 10, 16, 14, 2, 0, 0, time.UTC) }
+	}
+
+	_, err := Publish("test", cfpClient, trelloClient, PublicationReject, WithReport(&report, ReportCSV), now)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, "talk_id,title,format,category,speakers,previous_state,action,response,timestamp", lines[0])
+	assert.Equal(
+		t,
+		"Hj2ZNh7ydvOnpg9TBHeL,An advanced talk in category 1,Format 1,Category 1,Dev from UK,submitted,rejected,Proposal with ID Hj2ZNh7ydvOnpg9TBHeL is now rejected.,2042-10-16T14:02:00Z",
+		lines[1],
+	)
+}
+
+func TestResult_WriteReport(t *testing.T) {
+	result := Result{Entries: []Entry{
+		{
+			Board:    "Board",
+			Card:     "A talk",
+			Talk:     cfp.Talk{ID: "1", Title: "A talk", State: "submitted"},
+			Format:   "Conference",
+			Category: "Web",
+			Speakers: []string{"Jane", "John"},
+			Outcome:  OutcomeAccepted,
+			Response: "Proposal with ID 1 is now accepted.",
+			Time:     time.Date(2042, 10, 16, 15, 4, 0, 0, time.UTC),
+		},
+		{Board: "Board", Card: "Unknown talk", Outcome: OutcomeUnmatched, Err: errors.New("not found"), Time: time.Date(2042, 10, 16, 15, 5, 0, 0, time.UTC)},
+	}}
+
+	var csvReport strings.Builder
+	require.NoError(t, result.WriteReport(&csvReport, ReportCSV))
+	assert.Equal(
+		t,
+		"talk_id,title,format,category,speakers,previous_state,action,response,timestamp\n"+
+			"1,A talk,Conference,Web,\"Jane, John\",submitted,accepted,Proposal with ID 1 is now accepted.,2042-10-16T15:04:00Z\n"+
+			",Unknown talk,,,,,unmatched,not found,2042-10-16T15:05:00Z\n",
+		csvReport.String(),
+	)
+
+	var jsonReport strings.Builder
+	require.NoError(t, result.WriteReport(&jsonReport, ReportJSON))
+	assert.JSONEq(
+		t,
+		`[
+			{"talkId": "1", "title": "A talk", "format": "Conference", "category": "Web", "speakers": ["Jane", "John"],
+			 "previousState": "submitted", "action": "accepted", "response": "Proposal with ID 1 is now accepted.",
+			 "timestamp": "2042-10-16T15:04:00Z"},
+			{"talkId": "", "title": "Unknown talk", "format": "", "category": "", "speakers": null,
+			 "previousState": "", "action": "unmatched", "response": "not found", "timestamp": "2042-10-16T15:05:00Z"}
+		]`,
+		jsonReport.String(),
+	)
+}
+
+func TestValidate(t *testing.T) {
+	_, cfpClient := newCFPClient(t, "../cfp/testdata/export.json")
+	trelloClient := setupTrelloPublications(t)
+	_, err := trelloClient.CreateCard("Unknown talk", "", trello.List{ID: "Délibération Awesome Conference 2042 - Format 1-Backups"}, nil)
+	require.NoError(t, err)
+	_, err = trelloClient.CreateCard("A talk in category 2", "", trello.List{ID: "Délibération Awesome Conference 2042 - Format 1-" + trello.ListRefuses}, nil)
+	require.NoError(t, err)
+
+	findings, err := Validate("test", cfpClient, trelloClient, Rules{MaxTalksPerSpeaker: 1, Quotas: map[string]int{"Format 1": 3}})
+	require.NoError(t, err)
+
+	board := "Délibération Awesome Conference 2042 - Format 1"
+	assert.Equal(
+		t,
+		Findings{
+			{SeverityWarning, board, trello.ListSelection, "Another beginner talk in category 1", "talk is already accepted"},
+			{SeverityError, board, "Backups", "Unknown talk", "talk \"Unknown talk\" not found in CFP talks"},
+			{SeverityError, board, "", "", "4 talks selected for 3 slots of format Format 1"},
+			{SeverityError, board, "Backups", "A talk in category 2", "talk tzdLHxKDtVUXcJLd66TN has 2 cards"},
+			{SeverityError, board, trello.ListRefuses, "A talk in category 2", "talk tzdLHxKDtVUXcJLd66TN has 2 cards"},
+			{SeverityError, "", "", "", "speaker Leala Simard has 2 selected talks, 1 allowed: A beginner talk in category 1, Another talk in category 2"},
+			{SeverityError, "", "", "", "speaker Kari Angélil has 2 selected talks, 1 allowed: Another talk in category 2, Another beginner talk in category 1"},
+		},
+		findings,
+	)
+	assert.True(t, findings.HasErrors())
+}
+
+func TestSyncBack(t *testing.T) {
+	exportPath := writeExport(t, map[string]string{
+		"6grkSZ4ArcYr8BZfcw0o": "confirmed",
+		"dghzra8K2TfMYnBDjUEb": "declined",
+		"tsVw51wQQatiEsWzmWfx": "confirmed",
+	})
+	_, cfpClient := newCFPClient(t, exportPath)
+	trelloClient := setupTrelloPublications(t).(trello.FakeClient)
+	// already synced
+	_, err := trelloClient.CreateLabel("Confirmé", trello.Board{ID: "Délibération Awesome Conference 2042 - Format 1"}, trello.ColorGreen)
+	require.NoError(t, err)
+	require.NoError(t, trelloClient.AddLabel(trello.Card{ID: "Another beginner talk in category 1"}, trello.Label{ID: "Confirmé"}))
+	now := func() time.Time { return time.Date(2042, 10, 16, 14, 2, 0, 0, time.Local) }
+
+	err = SyncBack("test", cfpClient, trelloClient, func(p *publisher) { p.now = now })
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Confirmé"}, trelloClient.Cards["A beginner talk in category 1"].IDLabels)
+	assert.Equal(t, []string{"🎉 Confirmé dans Conference-Hall le 16/10 à 14h02"}, trelloClient.Comments["A beginner talk in category 1"])
+	assert.Equal(t, []string{"Décliné"}, trelloClient.Cards["Another talk in category 2"].IDLabels)
+	assert.Equal(t, []string{"🚫 Décliné dans Conference-Hall le 16/10 à 14h02"}, trelloClient.Comments["Another talk in category 2"])
+	assert.Empty(t, trelloClient.Comments["Another beginner talk in category 1"])
+
+	board := "Délibération Awesome Conference 2042 - Format 1"
+	assert.Equal(t, []string{"A beginner talk in category 1", "Another beginner talk in category 1"}, cardNames(trelloClient.Lists[board+"-"+trello.ListSelection]))
+	assert.Equal(t, []string{"Another talk in category two"}, cardNames(trelloClient.Lists[board+"-Désistements"]))
+
+	// nothing changes on a second run
+	require.NoError(t, SyncBack("test", cfpClient, trelloClient))
+	assert.Len(t, trelloClient.Comments["A beginner talk in category 1"], 1)
+	assert.Len(t, trelloClient.Comments["Another talk in category 2"], 1)
+}
+
+// writeExport writes a copy of the test export with the given talk states.
+func writeExport(t *testing.T, states map[string]string) string {
+	data, err := os.ReadFile("../cfp/testdata/export.json")
+	require.NoError(t, err)
+	var export map[string]any
+	require.NoError(t, json.Unmarshal(data, &export))
+	for _, talk := range export["talks"].([]any) {
+		talk := talk.(map[string]any)
+		if state, ok := states[talk["id"].(string)]; ok {
+			talk["state"] = state
+		}
+	}
+	data, err = json.Marshal(export)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "export.json")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+func cardNames(cards []trello.Card) []string {
+	names := make([]string, 0, len(cards))
+	for _, card := range cards {
+		names = append(names, card.Name)
+	}
+	return names
+}
+
+// newCFPClient returns a client of a fake Conference-Hall serving the export found at exportPath.
+func newCFPClient(t *testing.T, exportPath string) (*cfp.ConferenceHallServer, cfp.ConferenceHallClient) {
+	srv, stop := cfp.NewConferenceHallServer("12345", "67890", exportPath)
+	t.Cleanup(stop)
+	client := cfp.NewConferenceHallClient(
+		cfp.WithURL(srv.URL),
+		cfp.WithEventID("12345"),
+		cfp.WithAPIKey("67890"),
+		cfp.WithHTTPClient(srv.Client),
+	)
+	return srv, client
+}
+
 func setupTrello(t *testing.T) trello.Client {
 	org := trello.Organization{}
 	trelloClient := trello.NewFakeClient()
@@ -95,21 +397,74 @@ func setupTrello(t *testing.T) trello.Client {
 	return trelloClient
 }
 
+// setupTrelloPublications sets up the boards of setupTrello as organizers leave them after the deliberation: cards
+// renamed or linked to their proposal, backups accepted and a backup whose speakers withdrew.
+func setupTrelloPublications(t *testing.T) trello.Client {
+	trelloClient := setupTrello(t).(trello.FakeClient)
+	board1 := trello.Board{ID: "Délibération Awesome Conference 2042 - Format 1"}
+	// title fixed by an organizer, and a card only linked to its proposal
+	_, err := trelloClient.UpdateCard(trelloClient.Cards["Another talk in category 2"], "Another talk in category two", "🆔 `dghzra8K2TfMYnBDjUEb`")
+	require.NoError(t, err)
+	_, err = trelloClient.UpdateCard(trelloClient.Cards["Still another talk in category 2"], "Still another talk in category 2", "📜 [Proposal](https://conference-hall.io/organizer/event/12345/proposals/xdUotyrnjlJ0XiIUZasR)")
+	require.NoError(t, err)
+	listBackupsAcceptes1, err := trelloClient.CreateList("Backups Acceptés", board1)
+	require.NoError(t, err)
+	_, err = trelloClient.CreateCard("An intermediate talk in category 1", "", listBackupsAcceptes1, nil)
+	require.NoError(t, err)
+
+	// the speakers of a backup withdrew
+	board2 := trello.Board{ID: "Délibération Awesome Conference 2042 - Format 2"}
+	listDesistements2, err := trelloClient.CreateList("Désistements", board2)
+	require.NoError(t, err)
+	_, err = trelloClient.CreateList("Backups Acceptés", board2)
+	require.NoError(t, err)
+	require.NoError(t, trelloClient.MoveCard(trelloClient.Cards["A talk in category 1"], listDesistements2))
+
+	return trelloClient
+}
+
 func TestPublish_NoTrelloBoard(t *testing.T) {
 	// setup Conference Hall
-	srv, stop := cfp.NewConferenceHallServer("12345", "67890", "../cfp/testdata/export.json")
-	t.Cleanup(stop)
-	cfpClient := cfp.NewConferenceHallClient(
-		cfp.WithURL(srv.URL),
-		cfp.WithEventID("12345"),
-		cfp.WithAPIKey("67890"),
-		cfp.WithHTTPClient(srv.Client),
-	)
+	_, cfpClient := newCFPClient(t, "../cfp/testdata/export.json")
 
 	// setup Trello
 	trelloClient := trello.NewFakeClient()
 
-	err := Publish("test", cfpClient, trelloClient, PublicationAccept)
+	_, err := Publish("test", cfpClient, trelloClient, PublicationAccept)
 
 	assert.Error(t, err, "no board for CFP found in Trello")
 }
+
+func TestTalkIndex_Match(t *testing.T) {
+	talks := []cfp.Talk{
+		{ID: "1", Title: "A talk"},
+		{ID: "2", Title: "A shared title"},
+		{ID: "3", Title: "A shared title "},
+	}
+
+	tests := []struct {
+		name    string
+		card    trello.Card
+		want    string
+		wantErr string
+	}{
+		{name: "Matched by marker", card: trello.Card{Name: "A shared title", Desc: "🆔 `3`"}, want: "3"},
+		{name: "Matched by URL", card: trello.Card{Name: "Renamed talk", Desc: "📜 [Proposal](https://conference-hall.io/organizer/event/123/proposals/1)"}, want: "1"},
+		{name: "Matched by title", card: trello.Card{Name: "A talk"}, want: "1"},
+		{name: "Unknown ID", card: trello.Card{Name: "A talk", Desc: "🆔 `4`"}, wantErr: "talk 4 of card \"A talk\" not found in CFP talks"},
+		{name: "Unknown title", card: trello.Card{Name: "Another talk"}, wantErr: "talk \"Another talk\" not found in CFP talks"},
+		{name: "Ambiguous title", card: trello.Card{Name: "A shared title"}, wantErr: "no proposal ID found for card \"A shared title\" and several talks have this title"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			talk, err := newTalkIndex(cfp.Export{Talks: talks}).match(tc.card)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, talk.ID)
+		})
+	}
+}
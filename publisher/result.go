@@ -0,0 +1,195 @@
+package publisher
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bdxio/cfp-to-trello/cfp"
+	"github.com/bdxio/cfp-to-trello/trello"
+)
+
+type Outcome string
+
+const (
+	OutcomeAccepted  Outcome = "accepted"
+	OutcomeRejected  Outcome = "rejected"
+	OutcomeSkipped   Outcome = "skipped"
+	OutcomeWithdrawn Outcome = "withdrawn"
+	OutcomeUnmatched Outcome = "unmatched"
+	OutcomeFailed    Outcome = "failed"
+
+	// outcomePlanned is the outcome of talks to publish, until they are.
+	outcomePlanned Outcome = "planned"
+)
+
+// Entry is the outcome of the publication of a card.
+type Entry struct {
+	Board      string
+	Card       string
+	Talk       cfp.Talk // empty if the card is unmatched
+	Format     string
+	Category   string
+	Speakers   []string
+	Outcome    Outcome
+	Response   string // Conference-Hall response when published
+	SkipReason string // why the talk was left as it is when skipped
+	Err        error  // cause of the failure when unmatched or failed
+	Time       time.Time
+
+	board trello.Board
+	card  trello.Card // empty if the talk has no card
+}
+
+func (e Entry) details() string {
+	switch e.Outcome {
+	case OutcomeSkipped:
+		return e.SkipReason
+	case OutcomeWithdrawn:
+		return "left " + e.Talk.State
+	case OutcomeUnmatched, OutcomeFailed:
+		return e.Err.Error()
+	default:
+		return e.Response
+	}
+}
+
+// Result holds the outcome of every card processed by a publication.
+type Result struct {
+	Entries []Entry
+}
+
+func (r *Result) add(e Entry) {
+	r.Entries = append(r.Entries, e)
+}
+
+// Count returns the number of entries with the given outcome.
+func (r Result) Count(outcome Outcome) int {
+	n := 0
+	for _, e := range r.Entries {
+		if e.Outcome == outcome {
+			n++
+		}
+	}
+	return n
+}
+
+// Failures returns the number of cards that could not be published.
+func (r Result) Failures() int {
+	return r.Count(OutcomeUnmatched) + r.Count(OutcomeFailed)
+}
+
+// Print prints a table of all entries, followed by the count of each outcome.
+func (r Result) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "BOARD\tTALK\tOUTCOME\tDETAILS")
+	for _, e := range r.Entries {
+		title := e.Talk.Title
+		if title == "" {
+			title = e.Card
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Board, title, e.Outcome, e.details())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(
+		w,
+		"\n%d accepted, %d rejected, %d skipped, %d withdrawn, %d unmatched, %d failed\n",
+		r.Count(OutcomeAccepted),
+		r.Count(OutcomeRejected),
+		r.Count(OutcomeSkipped),
+		r.Count(OutcomeWithdrawn),
+		r.Count(OutcomeUnmatched),
+		r.Count(OutcomeFailed),
+	)
+	return err
+}
+
+type ReportFormat string
+
+const (
+	ReportCSV  ReportFormat = "csv"
+	ReportJSON ReportFormat = "json"
+)
+
+type reportRow struct {
+	TalkID        string    `json:"talkId"`
+	Title         string    `json:"title"`
+	Format        string    `json:"format"`
+	Category      string    `json:"category"`
+	Speakers      []string  `json:"speakers"`
+	PreviousState string    `json:"previousState"`
+	Action        Outcome   `json:"action"`
+	Response      string    `json:"response"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+func (e Entry) reportRow() reportRow {
+	row := reportRow{
+		TalkID:        e.Talk.ID,
+		Title:         e.Talk.Title,
+		Format:        e.Format,
+		Category:      e.Category,
+		Speakers:      e.Speakers,
+		PreviousState: e.Talk.State,
+		Action:        e.Outcome,
+		Response:      e.Response,
+		Timestamp:     e.Time,
+	}
+	if row.Title == "" {
+		row.Title = e.Card
+	}
+	if e.Err != nil {
+		row.Response = e.Err.Error()
+	}
+	return row
+}
+
+// WriteReport writes the entries as a CSV or JSON report.
+func (r Result) WriteReport(w io.Writer, format ReportFormat) error {
+	rows := make([]reportRow, 0, len(r.Entries))
+	for _, e := range r.Entries {
+		rows = append(rows, e.reportRow())
+	}
+	switch format {
+	case ReportCSV:
+		return writeCSVReport(w, rows)
+	case ReportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	default:
+		return fmt.Errorf("unknown report format %s", format)
+	}
+}
+
+func writeCSVReport(w io.Writer, rows []reportRow) error {
+	cw := csv.NewWriter(w)
+	header := []string{"talk_id", "title", "format", "category", "speakers", "previous_state", "action", "response", "timestamp"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{
+			row.TalkID,
+			row.Title,
+			row.Format,
+			row.Category,
+			strings.Join(row.Speakers, ", "),
+			row.PreviousState,
+			string(row.Action),
+			row.Response,
+			row.Timestamp.Format(time.RFC3339),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,110 @@
+package publisher
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bdxio/cfp-to-trello/cfp"
+	"github.com/bdxio/cfp-to-trello/layout"
+	"github.com/bdxio/cfp-to-trello/trello"
+)
+
+// stateLabel is the label put on the cards of talks confirmed or declined by their speakers.
+type stateLabel struct {
+	name    string
+	color   trello.Color
+	comment string
+}
+
+var stateLabels = map[string]stateLabel{
+	cfp.StateConfirmed: {name: "Confirmé", color: trello.ColorGreen, comment: "🎉 Confirmé dans Conference-Hall"},
+	cfp.StateDeclined:  {name: "Décliné", color: trello.ColorRed, comment: "🚫 Décliné dans Conference-Hall"},
+}
+
+// SyncBack reflects on the cards the talks confirmed or declined by their speakers in Conference-Hall.
+// Cards are labelled with the new state and commented, declined talks being moved to the withdrawals list.
+// Cards already labelled are left untouched.
+func SyncBack(orgName string, cfpClient cfp.ConferenceHallClient, trelloClient trello.Client, opts ...Option) error {
+	p := newPublisher(opts...)
+
+	export, err := cfpClient.GetExport()
+	if err != nil {
+		return err
+	}
+	organization, err := trelloClient.GetOrganization(orgName)
+	if err != nil {
+		return err
+	}
+	boards, err := trelloClient.GetBoards(organization, trello.PermissionLevelOrg)
+	if err != nil {
+		return err
+	}
+	boards = p.layout.FilterBoards(boards, export.Name, getFormats(export.Formats))
+	if len(boards) == 0 {
+		return errors.New("no board for CFP found in Trello")
+	}
+	withdrawals, ok := p.layout.ListName(layout.RoleWithdrawals)
+	if !ok {
+		return fmt.Errorf("no list with role %s in layout", layout.RoleWithdrawals)
+	}
+
+	index := newTalkIndex(export)
+	for _, board := range boards {
+		lists, err := trelloClient.GetLists(board)
+		if err != nil {
+			return err
+		}
+		for _, list := range lists {
+			cards, err := trelloClient.GetCards(list)
+			if err != nil {
+				return err
+			}
+			for _, card := range cards {
+				talk, err := index.match(card)
+				if err != nil {
+					log.Printf("⚠️ %v\n", err)
+					continue
+				}
+				sl, ok := stateLabels[talk.State]
+				if !ok {
+					continue
+				}
+				label, err := trelloClient.CreateLabel(sl.name, board, sl.color)
+				if err != nil {
+					return err
+				}
+				if card.HasLabel(label) {
+					continue
+				}
+
+				log.Printf("Talk %s is %s\n", talk.Title, talk.State)
+				if talk.State == cfp.StateDeclined && list.Name != withdrawals {
+					target, ok := getList(lists, withdrawals)
+					if !ok {
+						if target, err = trelloClient.CreateList(withdrawals, board); err != nil {
+							return err
+						}
+						lists = append(lists, target)
+					}
+					if err := trelloClient.MoveCard(card, target); err != nil {
+						return err
+					}
+				}
+				if err := trelloClient.AddLabel(card, label); err != nil {
+					return err
+				}
+				if err := trelloClient.CreateComment(fmt.Sprintf("%s %s", sl.comment, stamp(p.now())), card); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// stamp formats a date as written in card comments, e.g. "le 16/10 à 14h02".
+func stamp(t time.Time) string {
+	return t.Format("le 02/01 à 15h04")
+}
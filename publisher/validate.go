@@ -0,0 +1,200 @@
+package publisher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bdxio/cfp-to-trello/cfp"
+	"github.com/bdxio/cfp-to-trello/layout"
+	"github.com/bdxio/cfp-to-trello/trello"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is an issue found in the deliberation boards.
+type Finding struct {
+	Severity Severity
+	Board    string
+	List     string
+	Card     string
+	Message  string
+}
+
+type Findings []Finding
+
+// HasErrors returns true if any finding is an error.
+func (f Findings) HasErrors() bool {
+	for _, finding := range f {
+		if finding.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Print prints a table of the findings.
+func (f Findings) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEVERITY\tBOARD\tLIST\tCARD\tMESSAGE")
+	for _, finding := range f {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", finding.Severity, finding.Board, finding.List, finding.Card, finding.Message)
+	}
+	return tw.Flush()
+}
+
+// Rules are the constraints the selection must satisfy.
+type Rules struct {
+	// MaxTalksPerSpeaker is the maximum number of selected talks of a speaker, unlimited if 0.
+	MaxTalksPerSpeaker int
+	// Quotas are the number of slots of formats, by format name. Formats without quota are unlimited.
+	Quotas map[string]int
+}
+
+// placement is the place of a card in the boards.
+type placement struct {
+	board trello.Board
+	list  trello.List
+	card  trello.Card
+	talk  cfp.Talk
+}
+
+// Validate checks the deliberation boards against the CFP before publishing.
+// Talks in the selection and accepted backups lists are considered selected.
+func Validate(orgName string, cfpClient cfp.ConferenceHallClient, trelloClient trello.Client, rules Rules, opts ...Option) (Findings, error) {
+	p := newPublisher(opts...)
+
+	export, err := cfpClient.GetExport()
+	if err != nil {
+		return nil, err
+	}
+	organization, err := trelloClient.GetOrganization(orgName)
+	if err != nil {
+		return nil, err
+	}
+	boards, err := trelloClient.GetBoards(organization, trello.PermissionLevelOrg)
+	if err != nil {
+		return nil, err
+	}
+	formats := getFormats(export.Formats)
+	boards = p.layout.FilterBoards(boards, export.Name, formats)
+	if len(boards) == 0 {
+		return nil, errors.New("no board for CFP found in Trello")
+	}
+	boardFormats := make(map[string]string, len(formats))
+	for _, format := range formats {
+		boardFormats[p.layout.BoardNameFor(export.Name, format)] = format
+	}
+
+	selectionLists := make(map[string]struct{})
+	for _, role := range []layout.Role{layout.RoleSelection, layout.RoleAcceptedBackups} {
+		if name, ok := p.layout.ListName(role); ok {
+			selectionLists[name] = struct{}{}
+		}
+	}
+
+	index := newTalkIndex(export)
+	var findings Findings
+	var talkIDs []string
+	placements := make(map[string][]placement)
+	selected := make(map[string][]placement)
+	for _, board := range boards {
+		lists, err := trelloClient.GetLists(board)
+		if err != nil {
+			return nil, err
+		}
+		for _, list := range lists {
+			cards, err := trelloClient.GetCards(list)
+			if err != nil {
+				return nil, err
+			}
+			_, isSelection := selectionLists[list.Name]
+			for _, card := range cards {
+				talk, err := index.match(card)
+				if err != nil {
+					findings = append(findings, Finding{SeverityError, board.Name, list.Name, card.Name, err.Error()})
+					continue
+				}
+				at := placement{board, list, card, talk}
+				if _, ok := placements[talk.ID]; !ok {
+					talkIDs = append(talkIDs, talk.ID)
+				}
+				placements[talk.ID] = append(placements[talk.ID], at)
+				if !isSelection {
+					continue
+				}
+				selected[board.Name] = append(selected[board.Name], at)
+				switch {
+				case talk.IsAccepted():
+					findings = append(findings, Finding{SeverityWarning, board.Name, list.Name, card.Name, fmt.Sprintf("talk is already %s", talk.State)})
+				case !talk.IsSubmitted():
+					findings = append(findings, Finding{SeverityError, board.Name, list.Name, card.Name, fmt.Sprintf("selected talk is %s", talk.State)})
+				}
+			}
+		}
+
+		format := boardFormats[board.Name]
+		if quota, ok := rules.Quotas[format]; ok && len(selected[board.Name]) > quota {
+			findings = append(findings, Finding{
+				Severity: SeverityError,
+				Board:    board.Name,
+				Message:  fmt.Sprintf("%d talks selected for %d slots of format %s", len(selected[board.Name]), quota, format),
+			})
+		}
+	}
+
+	for _, id := range talkIDs {
+		if len(placements[id]) < 2 {
+			continue
+		}
+		for _, at := range placements[id] {
+			findings = append(findings, Finding{SeverityError, at.board.Name, at.list.Name, at.card.Name, fmt.Sprintf("talk %s has %d cards", id, len(placements[id]))})
+		}
+	}
+
+	if rules.MaxTalksPerSpeaker > 0 {
+		findings = append(findings, speakerOverloads(boards, selected, index, rules.MaxTalksPerSpeaker)...)
+	}
+	return findings, nil
+}
+
+// speakerOverloads returns a finding for every speaker having more selected talks than allowed.
+func speakerOverloads(boards []trello.Board, selected map[string][]placement, index talkIndex, max int) Findings {
+	talks := make(map[string][]string)
+	for _, board := range boards {
+		for _, at := range selected[board.Name] {
+			for _, uid := range at.talk.Speakers {
+				talks[uid] = append(talks[uid], at.talk.Title)
+			}
+		}
+	}
+	uids := make([]string, 0, len(talks))
+	for uid := range talks {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+
+	var findings Findings
+	for _, uid := range uids {
+		if len(talks[uid]) <= max {
+			continue
+		}
+		name := index.speakers[uid]
+		if name == "" {
+			name = uid
+		}
+		findings = append(findings, Finding{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("speaker %s has %d selected talks, %d allowed: %s", name, len(talks[uid]), max, strings.Join(talks[uid], ", ")),
+		})
+	}
+	return findings
+}
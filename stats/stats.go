@@ -0,0 +1,327 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bdxio/cfp-to-trello/cfp"
+)
+
+// noCategory names the category of the proposals without any.
+const noCategory = "-"
+
+// ratingBuckets are the bounds of the rating histograms.
+var ratingBuckets = []string{"0-1", "1-2", "2-3", "3-4", "4-5"}
+
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+)
+
+// ParseFormat returns the format of statistics named f.
+func ParseFormat(f string) (Format, error) {
+	switch format := Format(f); format {
+	case FormatText, FormatJSON, FormatCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown stats format %s", f)
+	}
+}
+
+// Stats holds the figures of a CFP.
+type Stats struct {
+	Event      string                    `json:"event"`
+	Proposals  int                       `json:"proposals"`
+	Formats    []string                  `json:"formats"`
+	Categories []string                  `json:"categories"`
+	Counts     map[string]map[string]int `json:"counts"` // proposals by format then category
+	Levels     []Share                   `json:"levels"`
+	Languages  []Share                   `json:"languages"`
+	Ratings    []Ratings                 `json:"ratings"` // by category
+	Speakers   Speakers                  `json:"speakers"`
+}
+
+// Share is the number of proposals having a value, e.g. an audience level.
+type Share struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Ratings sums up the ratings of the proposals of a category.
+type Ratings struct {
+	Category  string  `json:"category"`
+	Histogram []int   `json:"histogram"` // number of proposals in each rating bucket
+	Mean      float64 `json:"mean"`
+	Median    float64 `json:"median"`
+}
+
+// Speakers counts the distinct speakers of the proposals.
+type Speakers struct {
+	Total                int `json:"total"`
+	Local                int `json:"local"`
+	Remote               int `json:"remote"`
+	Unlocated            int `json:"unlocated"`
+	WithSeveralProposals int `json:"withSeveralProposals"`
+}
+
+// LocalShare returns the percentage of local speakers.
+func (s Speakers) LocalShare() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Local) * 100 / float64(s.Total)
+}
+
+// Compute computes the statistics of the proposals of an event.
+func Compute(event cfp.Event) Stats {
+	s := Stats{
+		Event:      event.Name,
+		Proposals:  len(event.Proposals),
+		Formats:    event.Formats,
+		Categories: event.Categories,
+		Counts:     make(map[string]map[string]int, len(event.Formats)),
+	}
+	for _, format := range event.Formats {
+		s.Counts[format] = make(map[string]int, len(event.Categories))
+	}
+
+	levels := make(map[string]int)
+	languages := make(map[string]int)
+	ratings := make(map[string][]float64)
+	proposalsBySpeaker := make(map[string]int)
+	speakers := make(map[string]cfp.ProposalSpeaker)
+	for _, p := range event.Proposals {
+		category := p.Category
+		if category == "" {
+			category = noCategory
+		}
+		if _, ok := s.Counts[p.Format]; !ok {
+			s.Counts[p.Format] = make(map[string]int)
+		}
+		s.Counts[p.Format][category]++
+		levels[p.AudienceLevel]++
+		// proposals given in several languages are counted in each of them
+		for _, language := range strings.Split(p.Language, "/") {
+			languages[language]++
+		}
+		ratings[category] = append(ratings[category], p.Rating)
+		for _, speaker := range p.Speakers {
+			proposalsBySpeaker[speaker.ID]++
+			speakers[speaker.ID] = speaker
+		}
+	}
+
+	s.Levels = shares(levels)
+	s.Languages = shares(languages)
+	categories := event.Categories
+	if _, ok := ratings[noCategory]; ok {
+		categories = append(categories[:len(categories):len(categories)], noCategory)
+	}
+	for _, category := range categories {
+		if len(ratings[category]) == 0 {
+			continue
+		}
+		s.Ratings = append(s.Ratings, newRatings(category, ratings[category]))
+	}
+
+	for id, speaker := range speakers {
+		s.Speakers.Total++
+		switch {
+		case speaker.Local:
+			s.Speakers.Local++
+		case speaker.City == "":
+			s.Speakers.Unlocated++
+		default:
+			s.Speakers.Remote++
+		}
+		if proposalsBySpeaker[id] > 1 {
+			s.Speakers.WithSeveralProposals++
+		}
+	}
+	return s
+}
+
+func shares(counts map[string]int) []Share {
+	s := make([]Share, 0, len(counts))
+	for value, count := range counts {
+		if value == "" {
+			value = noCategory
+		}
+		s = append(s, Share{Value: value, Count: count})
+	}
+	sort.Slice(s, func(i, j int) bool {
+		if s[i].Count != s[j].Count {
+			return s[i].Count > s[j].Count
+		}
+		return s[i].Value < s[j].Value
+	})
+	return s
+}
+
+func newRatings(category string, values []float64) Ratings {
+	r := Ratings{Category: category, Histogram: make([]int, len(ratingBuckets))}
+	sort.Float64s(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+		bucket := int(v)
+		if bucket >= len(ratingBuckets) {
+			bucket = len(ratingBuckets) - 1
+		}
+		if bucket < 0 {
+			bucket = 0
+		}
+		r.Histogram[bucket]++
+	}
+	r.Mean = sum / float64(len(values))
+	if n := len(values); n%2 == 1 {
+		r.Median = values[n/2]
+	} else {
+		r.Median = (values[n/2-1] + values[n/2]) / 2
+	}
+	return r
+}
+
+// Write writes the statistics as text, JSON or CSV.
+func (s Stats) Write(w io.Writer, format Format) error {
+	switch format {
+	case FormatText:
+		return s.writeText(w)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	case FormatCSV:
+		return s.writeCSV(w)
+	default:
+		return fmt.Errorf("unknown stats format %s", format)
+	}
+}
+
+func (s Stats) categories() []string {
+	categories := make([]string, 0, len(s.Categories)+1)
+	for _, category := range append(s.Categories[:len(s.Categories):len(s.Categories)], noCategory) {
+		for _, counts := range s.Counts {
+			if counts[category] > 0 {
+				categories = append(categories, category)
+				break
+			}
+		}
+	}
+	return categories
+}
+
+func (s Stats) formats() []string {
+	formats := make([]string, 0, len(s.Counts))
+	known := make(map[string]bool, len(s.Formats))
+	for _, format := range s.Formats {
+		known[format] = true
+		if len(s.Counts[format]) > 0 {
+			formats = append(formats, format)
+		}
+	}
+	others := make([]string, 0)
+	for format := range s.Counts {
+		if !known[format] {
+			others = append(others, format)
+		}
+	}
+	sort.Strings(others)
+	return append(formats, others...)
+}
+
+func (s Stats) writeText(w io.Writer) error {
+	fmt.Fprintf(w, "%s: %d proposals\n\n", s.Event, s.Proposals)
+
+	categories := s.categories()
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "FORMAT\t%s\tTOTAL\n", strings.Join(categories, "\t"))
+	for _, format := range s.formats() {
+		total := 0
+		fmt.Fprintf(tw, "%s", format)
+		for _, category := range categories {
+			fmt.Fprintf(tw, "\t%d", s.Counts[format][category])
+			total += s.Counts[format][category]
+		}
+		fmt.Fprintf(tw, "\t%d\n", total)
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "LEVEL\tPROPOSALS")
+	for _, level := range s.Levels {
+		fmt.Fprintf(tw, "%s\t%d\n", level.Value, level.Count)
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "LANGUAGE\tPROPOSALS")
+	for _, language := range s.Languages {
+		fmt.Fprintf(tw, "%s\t%d\n", language.Value, language.Count)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, r := range s.Ratings {
+		fmt.Fprintf(w, "\n%s: mean %.2f, median %.2f\n", r.Category, r.Mean, r.Median)
+		for i, count := range r.Histogram {
+			fmt.Fprintf(w, "  %s %s %d\n", ratingBuckets[i], strings.Repeat("█", count), count)
+		}
+	}
+
+	_, err := fmt.Fprintf(
+		w,
+		"\n%d speakers: %d local 🍷 (%.0f%%), %d remote, %d without address, %d with several proposals\n",
+		s.Speakers.Total,
+		s.Speakers.Local,
+		s.Speakers.LocalShare(),
+		s.Speakers.Remote,
+		s.Speakers.Unlocated,
+		s.Speakers.WithSeveralProposals,
+	)
+	return err
+}
+
+func (s Stats) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	rows := [][]string{{"statistic", "key", "subkey", "value"}}
+	rows = append(rows, []string{"proposals", "", "", strconv.Itoa(s.Proposals)})
+	for _, format := range s.formats() {
+		for _, category := range s.categories() {
+			rows = append(rows, []string{"count", format, category, strconv.Itoa(s.Counts[format][category])})
+		}
+	}
+	for _, level := range s.Levels {
+		rows = append(rows, []string{"level", level.Value, "", strconv.Itoa(level.Count)})
+	}
+	for _, language := range s.Languages {
+		rows = append(rows, []string{"language", language.Value, "", strconv.Itoa(language.Count)})
+	}
+	for _, r := range s.Ratings {
+		for i, count := range r.Histogram {
+			rows = append(rows, []string{"rating", r.Category, ratingBuckets[i], strconv.Itoa(count)})
+		}
+		rows = append(rows, []string{"rating", r.Category, "mean", strconv.FormatFloat(r.Mean, 'f', 2, 64)})
+		rows = append(rows, []string{"rating", r.Category, "median", strconv.FormatFloat(r.Median, 'f', 2, 64)})
+	}
+	rows = append(
+		rows,
+		[]string{"speakers", "total", "", strconv.Itoa(s.Speakers.Total)},
+		[]string{"speakers", "local", "", strconv.Itoa(s.Speakers.Local)},
+		[]string{"speakers", "remote", "", strconv.Itoa(s.Speakers.Remote)},
+		[]string{"speakers", "unlocated", "", strconv.Itoa(s.Speakers.Unlocated)},
+		[]string{"speakers", "several proposals", "", strconv.Itoa(s.Speakers.WithSeveralProposals)},
+	)
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
@@ -0,0 +1,89 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdxio/cfp-to-trello/cfp"
+	"github.com/bdxio/cfp-to-trello/geo"
+)
+
+func TestCompute(t *testing.T) {
+	event, err := cfp.Parse("../cfp/testdata/export.json", geo.FakeLocate)
+	require.NoError(t, err)
+
+	s := Compute(event)
+
+	assert.Equal(t, "Awesome Conference 2042", s.Event)
+	assert.Equal(t, 8, s.Proposals)
+	assert.Equal(t, map[string]map[string]int{"Format 1": {"Category 1": 5, "Category 2": 3}, "Format 2": {}}, s.Counts)
+	assert.Equal(t, []Share{{"Avancé", 4}, {"Débutant", 3}, {"Intermédiaire", 1}}, s.Levels)
+	// the proposal given in English or French is counted in both
+	assert.Equal(t, []Share{{"🇫🇷", 7}, {"🇬🇧", 2}}, s.Languages)
+	require.Len(t, s.Ratings, 2)
+	assert.Equal(t, "Category 1", s.Ratings[0].Category)
+	assert.Equal(t, []int{0, 0, 1, 3, 1}, s.Ratings[0].Histogram)
+	assert.InDelta(t, 3.368, s.Ratings[0].Mean, 0.001)
+	assert.Equal(t, 3.4, s.Ratings[0].Median)
+	assert.Equal(t, []int{0, 1, 0, 0, 2}, s.Ratings[1].Histogram)
+	assert.Equal(t, 4.123, s.Ratings[1].Median)
+	assert.Equal(t, Speakers{Total: 5, Local: 1, Remote: 3, Unlocated: 1, WithSeveralProposals: 4}, s.Speakers)
+	assert.Equal(t, 20.0, s.Speakers.LocalShare())
+}
+
+func TestCompute_NoCategory(t *testing.T) {
+	event := cfp.Event{
+		Name:    "Awesome Conference 2042",
+		Formats: []string{"Talk"},
+		Proposals: []cfp.Proposal{
+			{ID: "1", Format: "Talk", Rating: 5},
+			{ID: "2", Format: "Talk", Rating: 2},
+		},
+	}
+
+	s := Compute(event)
+
+	assert.Equal(t, map[string]map[string]int{"Talk": {"-": 2}}, s.Counts)
+	assert.Equal(t, []Ratings{{Category: "-", Histogram: []int{0, 0, 1, 0, 1}, Mean: 3.5, Median: 3.5}}, s.Ratings)
+}
+
+func TestStats_Write(t *testing.T) {
+	event, err := cfp.Parse("../cfp/testdata/export.json", geo.FakeLocate)
+	require.NoError(t, err)
+	s := Compute(event)
+
+	var text bytes.Buffer
+	require.NoError(t, s.Write(&text, FormatText))
+	assert.Contains(t, text.String(), "Awesome Conference 2042: 8 proposals\n")
+	assert.Contains(t, text.String(), "Format 1  5           3           8\n")
+	assert.Contains(t, text.String(), "Category 2: mean 3.25, median 4.12\n")
+	assert.Contains(t, text.String(), "  4-5 ██ 2\n")
+	assert.Contains(t, text.String(), "5 speakers: 1 local 🍷 (20%), 3 remote, 1 without address, 4 with several proposals\n")
+
+	var csv bytes.Buffer
+	require.NoError(t, s.Write(&csv, FormatCSV))
+	assert.Contains(t, csv.String(), "statistic,key,subkey,value\nproposals,,,8\ncount,Format 1,Category 1,5\ncount,Format 1,Category 2,3\n")
+	assert.Contains(t, csv.String(), "rating,Category 1,median,3.40\n")
+	assert.Contains(t, csv.String(), "speakers,several proposals,,4\n")
+
+	var data bytes.Buffer
+	require.NoError(t, s.Write(&data, FormatJSON))
+	var decoded Stats
+	require.NoError(t, json.Unmarshal(data.Bytes(), &decoded))
+	assert.Equal(t, s, decoded)
+
+	assert.EqualError(t, s.Write(&data, "xml"), "unknown stats format xml")
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("csv")
+	require.NoError(t, err)
+	assert.Equal(t, FormatCSV, format)
+
+	_, err = ParseFormat("xml")
+	assert.EqualError(t, err, "unknown stats format xml")
+}
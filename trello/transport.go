@@ -0,0 +1,186 @@
+package trello
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Trello allows 300 requests per 10 seconds for each API key and 100 requests per 10 seconds for each token.
+// See https://developer.atlassian.com/cloud/trello/guides/rest-api/rate-limits/
+const (
+	limitWindow = 10 * time.Second
+	keyLimit    = 300
+	tokenLimit  = 100
+)
+
+var (
+	ErrRateLimited  = errors.New("trello rate limit exceeded")
+	ErrUnauthorized = errors.New("trello request unauthorized")
+	ErrNotFound     = errors.New("trello resource not found")
+)
+
+// APIError is returned when Trello answers with an unsuccessful status code.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("trello: %s %s: %d %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+func newAPIError(req *http.Request, resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &APIError{
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+	}
+}
+
+// Transport throttles requests to stay under Trello rate limits, and retries the rate limited or failing ones.
+type Transport struct {
+	// Base is the RoundTripper actually sending requests, http.DefaultTransport if nil.
+	Base       http.RoundTripper
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	limiters   []*limiter
+}
+
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{
+		Base:       base,
+		MaxRetries: 5,
+		MinBackoff: 500 * time.Millisecond,
+		MaxBackoff: 30 * time.Second,
+		limiters:   []*limiter{newLimiter(keyLimit, limitWindow), newLimiter(tokenLimit, limitWindow)},
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		for _, l := range t.limiters {
+			if err := l.wait(req); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := t.base().RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		if !isRetryable(req.Method, resp.StatusCode) || attempt >= t.MaxRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+
+		delay := t.backoff(attempt, resp.Header.Get("Retry-After"))
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func isRetryable(method string, statusCode int) bool {
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	idempotent := method != http.MethodPost && method != http.MethodPatch
+	return idempotent && statusCode >= http.StatusInternalServerError
+}
+
+// backoff returns the delay to wait before the next attempt, as requested by the server if it did.
+func (t *Transport) backoff(attempt int, retryAfter string) time.Duration {
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		delay := time.Duration(seconds) * time.Second
+		if delay > t.MaxBackoff {
+			return t.MaxBackoff
+		}
+		return delay
+	}
+	delay := t.MinBackoff << attempt
+	if delay > t.MaxBackoff || delay <= 0 {
+		delay = t.MaxBackoff
+	}
+	// full jitter on the upper half, so that concurrent requests don't retry all at once
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// limiter allows at most limit requests in any sliding window.
+type limiter struct {
+	limit  int
+	window time.Duration
+	sent   []time.Time
+	mu     sync.Mutex
+}
+
+func newLimiter(limit int, window time.Duration) *limiter {
+	return &limiter{limit: limit, window: window, sent: make([]time.Time, 0, limit)}
+}
+
+func (l *limiter) wait(req *http.Request) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		expired := 0
+		for expired < len(l.sent) && now.Sub(l.sent[expired]) >= l.window {
+			expired++
+		}
+		l.sent = l.sent[expired:]
+		if len(l.sent) < l.limit {
+			l.sent = append(l.sent, now)
+			l.mu.Unlock()
+			return nil
+		}
+		delay := l.window - now.Sub(l.sent[0])
+		l.mu.Unlock()
+
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return req.Context().Err()
+		}
+	}
+}
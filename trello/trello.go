@@ -1,15 +1,18 @@
 package trello
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -26,10 +29,19 @@ const (
 type Client interface {
 	GetOrganization(name string) (Organization, error)
 	CreateBoard(org Organization, name string, permLvl PermissionLevel) (Board, error)
+	CloseBoard(board Board) error
+	DeleteBoard(board Board) error
 	CreateList(name string, board Board) (List, error)
 	CreateLabel(name string, board Board, color Color) (Label, error)
+	FindLabel(name string, board Board) (Label, bool, error)
 	CreateCard(name, desc string, list List, labels []Label) (Card, error)
+	UpdateCard(card Card, name, desc string) (Card, error)
+	MoveCard(card Card, list List) error
+	AddLabel(card Card, label Label) error
+	SetCover(card Card, imageURL string) error
 	CreateComment(text string, card Card) error
+	CreateCustomField(name string, board Board, fieldType CustomFieldType) (CustomField, error)
+	SetCustomFieldValue(card Card, field CustomField, value string) error
 	GetBoards(organization Organization, permLvl PermissionLevel) ([]Board, error)
 	GetLists(board Board) ([]List, error)
 	GetCards(list List) ([]Card, error)
@@ -41,9 +53,10 @@ type Organization struct {
 }
 
 type Board struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-	URL  string `json:"url"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Closed bool   `json:"closed"`
 }
 
 type List struct {
@@ -62,6 +75,29 @@ type Card struct {
 	IDLabels []string
 }
 
+// HasLabel returns true if the label is put on the card.
+func (c Card) HasLabel(label Label) bool {
+	for _, id := range c.IDLabels {
+		if id == label.ID {
+			return true
+		}
+	}
+	return false
+}
+
+type CustomField struct {
+	ID   string          `json:"id"`
+	Name string          `json:"name"`
+	Type CustomFieldType `json:"type"`
+}
+
+type CustomFieldType string
+
+const (
+	CustomFieldNumber CustomFieldType = "number"
+	CustomFieldText   CustomFieldType = "text"
+)
+
 type PermissionLevel string
 
 const (
@@ -99,10 +135,47 @@ var endpoint = oauth1.Endpoint{
 	AccessTokenURL:  "https://trello.com/1/OAuthGetAccessToken",
 }
 
+// URL is the base URL of Trello REST API.
+const URL = "https://api.trello.com/1"
+
 type APIClient struct {
-	httpClient *http.Client
-	labels     map[string]Label
-	mu         sync.RWMutex
+	url          string
+	httpClient   *http.Client
+	labels       map[string]Label
+	boardLabels  map[string]bool
+	customFields map[string]CustomField
+	boardFields  map[string]bool
+	mu           sync.RWMutex
+}
+
+type APIClientOption func(c *APIClient)
+
+func WithURL(url string) APIClientOption {
+	return func(c *APIClient) {
+		c.url = url
+	}
+}
+
+// WithHTTPClient sets the HTTP client sending requests, it is expected to authenticate them and to handle rate limits.
+func WithHTTPClient(client *http.Client) APIClientOption {
+	return func(c *APIClient) {
+		c.httpClient = client
+	}
+}
+
+func NewAPIClient(opts ...APIClientOption) *APIClient {
+	client := &APIClient{
+		url:          URL,
+		httpClient:   http.DefaultClient,
+		labels:       make(map[string]Label),
+		boardLabels:  make(map[string]bool),
+		customFields: make(map[string]CustomField),
+		boardFields:  make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(client)
+	}
+	return client
 }
 
 func New(consumerKey, consumerSecret string) (*APIClient, error) {
@@ -190,12 +263,13 @@ func New(consumerKey, consumerSecret string) (*APIClient, error) {
 }
 
 func newClient(accessToken, accessSecret string, config *oauth1.Config) *APIClient {
-	httpClient := oauth1.NewClient(context.TODO(), config, &oauth1.Token{
+	oauthClient := oauth1.NewClient(context.TODO(), config, &oauth1.Token{
 		Token:       accessToken,
 		TokenSecret: accessSecret,
 	})
-	client := &APIClient{httpClient: httpClient, labels: make(map[string]Label)}
-	return client
+	// requests are throttled and retried before being signed, each attempt getting its own nonce
+	httpClient := &http.Client{Transport: NewTransport(oauthClient.Transport)}
+	return NewAPIClient(WithHTTPClient(httpClient))
 }
 
 func getStoredAuthPath() (path, dir string, err error) {
@@ -231,60 +305,82 @@ func getStoredToken() (*oauth1.Token, error) {
 	return nil, nil
 }
 
-func (c *APIClient) GetOrganization(name string) (Organization, error) {
-	resp, err := c.httpClient.Get(fmt.Sprintf("https://api.trello.com/1/organizations/%s", name))
+// do sends a request to Trello API and decodes the JSON response into v, if not nil.
+// An *APIError is returned if the response status code is not successful.
+func (c *APIClient) do(method, path string, values url.Values, body any, v any) error {
+	reqURL := c.url + path
+	if len(values) > 0 {
+		reqURL += "?" + values.Encode()
+	}
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, reqURL, reqBody)
 	if err != nil {
-		return Organization{}, err
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(req, resp)
+	}
+	if v == nil {
+		return nil
+	}
+	return common.UnmarshalBody(resp.Body, v)
+}
+
+func (c *APIClient) GetOrganization(name string) (Organization, error) {
 	var org Organization
-	if err := common.UnmarshalBody(resp.Body, &org); err != nil {
+	if err := c.do(http.MethodGet, "/organizations/"+name, nil, nil, &org); err != nil {
 		return Organization{}, err
 	}
 	return org, nil
 }
 
 func (c *APIClient) CreateBoard(org Organization, name string, permLvl PermissionLevel) (Board, error) {
-	postURL, err := url.Parse("https://api.trello.com/1/boards")
-	if err != nil {
-		return Board{}, err
-	}
-	values := postURL.Query()
+	values := url.Values{}
 	values.Add("name", name)
 	values.Add("defaultLabels", "false")
 	values.Add("defaultLists", "false")
 	values.Add("idOrganization", org.ID)
 	values.Add("prefs_permissionLevel", string(permLvl))
-	postURL.RawQuery = values.Encode()
-	resp, err := c.httpClient.Post(postURL.String(), "application/json", nil)
-	if err != nil {
-		return Board{}, err
-	}
-	defer resp.Body.Close()
 	var board Board
-	if err := common.UnmarshalBody(resp.Body, &board); err != nil {
+	if err := c.do(http.MethodPost, "/boards", values, nil, &board); err != nil {
 		return Board{}, err
 	}
 	return board, nil
 }
 
+func (c *APIClient) CloseBoard(board Board) error {
+	values := url.Values{}
+	values.Add("closed", "true")
+	return c.do(http.MethodPut, "/boards/"+board.ID, values, nil, nil)
+}
+
+func (c *APIClient) DeleteBoard(board Board) error {
+	return c.do(http.MethodDelete, "/boards/"+board.ID, nil, nil, nil)
+}
+
 func (c *APIClient) CreateList(name string, board Board) (List, error) {
-	postURL, err := url.Parse("https://api.trello.com/1/lists")
-	if err != nil {
-		return List{}, err
-	}
-	values := postURL.Query()
+	values := url.Values{}
 	values.Add("name", name)
 	values.Add("idBoard", board.ID)
 	values.Add("pos", "bottom")
-	postURL.RawQuery = values.Encode()
-	resp, err := c.httpClient.Post(postURL.String(), "application/json", nil)
-	if err != nil {
-		return List{}, err
-	}
-	defer resp.Body.Close()
 	var list List
-	if err := common.UnmarshalBody(resp.Body, &list); err != nil {
+	if err := c.do(http.MethodPost, "/lists", values, nil, &list); err != nil {
 		return List{}, err
 	}
 	return list, nil
@@ -293,27 +389,20 @@ func (c *APIClient) CreateList(name string, board Board) (List, error) {
 func (c *APIClient) CreateLabel(name string, board Board, color Color) (Label, error) {
 	// labels are specific to a board
 	labelKey := board.ID + "|" + name
+	if err := c.loadLabels(board); err != nil {
+		return Label{}, err
+	}
 	c.mu.RLock()
 	label, ok := c.labels[labelKey]
 	c.mu.RUnlock()
 	if ok {
 		return label, nil
 	}
-	postURL, err := url.Parse("https://api.trello.com/1/labels")
-	if err != nil {
-		return Label{}, err
-	}
-	values := postURL.Query()
+	values := url.Values{}
 	values.Add("name", name)
 	values.Add("color", string(color))
 	values.Add("idBoard", board.ID)
-	postURL.RawQuery = values.Encode()
-	resp, err := c.httpClient.Post(postURL.String(), "application/json", nil)
-	if err != nil {
-		return Label{}, err
-	}
-	defer resp.Body.Close()
-	if err := common.UnmarshalBody(resp.Body, &label); err != nil {
+	if err := c.do(http.MethodPost, "/labels", values, nil, &label); err != nil {
 		return Label{}, err
 	}
 	c.mu.Lock()
@@ -322,110 +411,206 @@ func (c *APIClient) CreateLabel(name string, board Board, color Color) (Label, e
 	return label, nil
 }
 
-func (c *APIClient) CreateCard(name, desc string, list List, labels []Label) (Card, error) {
-	postURL, err := url.Parse("https://api.trello.com/1/cards")
-	if err != nil {
-		return Card{}, err
+// FindLabel returns the label of the board with the given name, if any.
+func (c *APIClient) FindLabel(name string, board Board) (Label, bool, error) {
+	if err := c.loadLabels(board); err != nil {
+		return Label{}, false, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	label, ok := c.labels[board.ID+"|"+name]
+	return label, ok, nil
+}
+
+// loadLabels caches the existing labels of the board, once per board.
+func (c *APIClient) loadLabels(board Board) error {
+	c.mu.RLock()
+	loaded := c.boardLabels[board.ID]
+	c.mu.RUnlock()
+	if loaded {
+		return nil
 	}
-	values := postURL.Query()
+	var labels []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	// only the first 50 labels are returned by default, speaker labels may be far more numerous
+	values := url.Values{}
+	values.Add("limit", "1000")
+	if err := c.do(http.MethodGet, fmt.Sprintf("/boards/%s/labels", board.ID), values, nil, &labels); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, label := range labels {
+		c.labels[board.ID+"|"+label.Name] = Label{ID: label.ID}
+	}
+	c.boardLabels[board.ID] = true
+	return nil
+}
+
+func (c *APIClient) CreateCard(name, desc string, list List, labels []Label) (Card, error) {
+	values := url.Values{}
 	values.Add("name", name)
 	values.Add("desc", desc)
 	values.Add("idList", list.ID)
 	for _, label := range labels {
 		values.Add("idLabels", label.ID)
 	}
-	postURL.RawQuery = values.Encode()
-	resp, err := c.httpClient.Post(postURL.String(), "application/json", nil)
-	if err != nil {
-		return Card{}, err
-	}
-	defer resp.Body.Close()
 	var card Card
-	if err := common.UnmarshalBody(resp.Body, &card); err != nil {
+	if err := c.do(http.MethodPost, "/cards", values, nil, &card); err != nil {
 		return Card{}, err
 	}
 	return card, nil
 }
 
-func (c *APIClient) CreateComment(text string, card Card) error {
-	postURL, err := url.Parse(fmt.Sprintf("https://api.trello.com/1/cards/%s/actions/comments", card.ID))
-	if err != nil {
-		return err
+func (c *APIClient) UpdateCard(card Card, name, desc string) (Card, error) {
+	values := url.Values{}
+	values.Add("name", name)
+	values.Add("desc", desc)
+	var updated Card
+	if err := c.do(http.MethodPut, "/cards/"+card.ID, values, nil, &updated); err != nil {
+		return Card{}, err
 	}
-	values := postURL.Query()
+	return updated, nil
+}
+
+func (c *APIClient) MoveCard(card Card, list List) error {
+	values := url.Values{}
+	values.Add("idList", list.ID)
+	values.Add("pos", "bottom")
+	return c.do(http.MethodPut, "/cards/"+card.ID, values, nil, nil)
+}
+
+func (c *APIClient) AddLabel(card Card, label Label) error {
+	values := url.Values{}
+	values.Add("value", label.ID)
+	return c.do(http.MethodPost, fmt.Sprintf("/cards/%s/idLabels", card.ID), values, nil, nil)
+}
+
+// SetCover attaches the image found at the given URL to the card, and makes it the cover of the card.
+func (c *APIClient) SetCover(card Card, imageURL string) error {
+	values := url.Values{}
+	values.Add("url", imageURL)
+	values.Add("setCover", "true")
+	return c.do(http.MethodPost, fmt.Sprintf("/cards/%s/attachments", card.ID), values, nil, nil)
+}
+
+func (c *APIClient) CreateComment(text string, card Card) error {
+	values := url.Values{}
 	values.Add("text", text)
-	postURL.RawQuery = values.Encode()
-	resp, err := c.httpClient.Post(postURL.String(), "application/json", nil)
-	if err != nil {
+	return c.do(http.MethodPost, fmt.Sprintf("/cards/%s/actions/comments", card.ID), values, nil, nil)
+}
+
+func (c *APIClient) CreateCustomField(name string, board Board, fieldType CustomFieldType) (CustomField, error) {
+	// custom fields are specific to a board
+	fieldKey := board.ID + "|" + name
+	if err := c.loadCustomFields(board); err != nil {
+		return CustomField{}, err
+	}
+	c.mu.RLock()
+	field, ok := c.customFields[fieldKey]
+	c.mu.RUnlock()
+	if ok {
+		return field, nil
+	}
+	body := map[string]any{
+		"idModel":           board.ID,
+		"modelType":         "board",
+		"name":              name,
+		"type":              fieldType,
+		"pos":               "bottom",
+		"display_cardFront": true,
+	}
+	if err := c.do(http.MethodPost, "/customFields", nil, body, &field); err != nil {
+		return CustomField{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.customFields[fieldKey] = field
+	return field, nil
+}
+
+// loadCustomFields caches the existing custom fields of the board, once per board.
+func (c *APIClient) loadCustomFields(board Board) error {
+	c.mu.RLock()
+	loaded := c.boardFields[board.ID]
+	c.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+	var fields []CustomField
+	if err := c.do(http.MethodGet, fmt.Sprintf("/boards/%s/customFields", board.ID), nil, nil, &fields); err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, field := range fields {
+		c.customFields[board.ID+"|"+field.Name] = field
+	}
+	c.boardFields[board.ID] = true
 	return nil
 }
 
-func (c *APIClient) GetBoards(organization Organization, permLvl PermissionLevel) ([]Board, error) {
-	getURL, err := url.Parse(fmt.Sprintf("https://api.trello.com/1/organizations/%s/boards", organization.ID))
-	if err != nil {
-		return nil, err
+func (c *APIClient) SetCustomFieldValue(card Card, field CustomField, value string) error {
+	body := map[string]any{
+		"value": map[CustomFieldType]string{field.Type: value},
 	}
-	values := getURL.Query()
+	return c.do(http.MethodPut, fmt.Sprintf("/cards/%s/customField/%s/item", card.ID, field.ID), nil, body, nil)
+}
+
+func (c *APIClient) GetBoards(organization Organization, permLvl PermissionLevel) ([]Board, error) {
+	values := url.Values{}
 	values.Add("filter", string(permLvl))
 	values.Add("fields", "id")
 	values.Add("fields", "name")
-	getURL.RawQuery = values.Encode()
-	resp, err := c.httpClient.Get(getURL.String())
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+	values.Add("fields", "closed")
 	var boards []Board
-	if err := common.UnmarshalBody(resp.Body, &boards); err != nil {
+	if err := c.do(http.MethodGet, fmt.Sprintf("/organizations/%s/boards", organization.ID), values, nil, &boards); err != nil {
 		return nil, err
 	}
 	return boards, nil
 }
 
 func (c *APIClient) GetLists(board Board) ([]List, error) {
-	resp, err := c.httpClient.Get(fmt.Sprintf("https://api.trello.com/1/boards/%s/lists", board.ID))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
 	var lists []List
-	if err := common.UnmarshalBody(resp.Body, &lists); err != nil {
+	if err := c.do(http.MethodGet, fmt.Sprintf("/boards/%s/lists", board.ID), nil, nil, &lists); err != nil {
 		return nil, err
 	}
 	return lists, nil
 }
 
 func (c *APIClient) GetCards(list List) ([]Card, error) {
-	resp, err := c.httpClient.Get(fmt.Sprintf("https://api.trello.com/1/lists/%s/cards", list.ID))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
 	var cards []Card
-	if err := common.UnmarshalBody(resp.Body, &cards); err != nil {
+	if err := c.do(http.MethodGet, fmt.Sprintf("/lists/%s/cards", list.ID), nil, nil, &cards); err != nil {
 		return nil, err
 	}
 	return cards, nil
 }
 
 type FakeClient struct {
-	Boards   map[string][]List
-	Lists    map[string][]Card
-	Labels   map[string]Color
-	Cards    map[string]Card
-	Comments map[string][]string
+	Boards            map[string][]List
+	Lists             map[string][]Card
+	Labels            map[string]Color
+	Cards             map[string]Card
+	Comments          map[string][]string
+	Covers            map[string]string
+	CustomFields      map[string]CustomFieldType
+	CustomFieldValues map[string]map[string]string
+	ClosedBoards      map[string]bool
 }
 
 func NewFakeClient() FakeClient {
 	return FakeClient{
-		Boards:   make(map[string][]List),
-		Lists:    make(map[string][]Card),
-		Labels:   make(map[string]Color),
-		Cards:    make(map[string]Card),
-		Comments: make(map[string][]string),
+		Boards:            make(map[string][]List),
+		Lists:             make(map[string][]Card),
+		Labels:            make(map[string]Color),
+		Cards:             make(map[string]Card),
+		Comments:          make(map[string][]string),
+		Covers:            make(map[string]string),
+		CustomFields:      make(map[string]CustomFieldType),
+		CustomFieldValues: make(map[string]map[string]string),
+		ClosedBoards:      make(map[string]bool),
 	}
 }
 
@@ -439,6 +624,33 @@ func (c FakeClient) CreateBoard(_ Organization, name string, _ PermissionLevel)
 	return board, nil
 }
 
+func (c FakeClient) CloseBoard(board Board) error {
+	if _, ok := c.Boards[board.ID]; !ok {
+		return fmt.Errorf("board %s doesn't exist", board.ID)
+	}
+	c.ClosedBoards[board.ID] = true
+	return nil
+}
+
+func (c FakeClient) DeleteBoard(board Board) error {
+	lists, ok := c.Boards[board.ID]
+	if !ok {
+		return fmt.Errorf("board %s doesn't exist", board.ID)
+	}
+	for _, list := range lists {
+		for _, card := range c.Lists[list.ID] {
+			delete(c.Cards, card.ID)
+			delete(c.Comments, card.ID)
+			delete(c.Covers, card.ID)
+			delete(c.CustomFieldValues, card.ID)
+		}
+		delete(c.Lists, list.ID)
+	}
+	delete(c.Boards, board.ID)
+	delete(c.ClosedBoards, board.ID)
+	return nil
+}
+
 func (c FakeClient) CreateList(name string, board Board) (List, error) {
 	if _, ok := c.Boards[board.ID]; !ok {
 		return List{}, fmt.Errorf("board %s doesn't exist", board.ID)
@@ -458,6 +670,14 @@ func (c FakeClient) CreateLabel(name string, board Board, color Color) (Label, e
 	return label, nil
 }
 
+func (c FakeClient) FindLabel(name string, board Board) (Label, bool, error) {
+	if _, ok := c.Boards[board.ID]; !ok {
+		return Label{}, false, fmt.Errorf("board %s doesn't exist", board.ID)
+	}
+	_, ok := c.Labels[name]
+	return Label{ID: name}, ok, nil
+}
+
 func (c FakeClient) CreateCard(name, desc string, list List, labels []Label) (Card, error) {
 	if _, ok := c.Lists[list.ID]; !ok {
 		return Card{}, fmt.Errorf("list %s doesn't exist", list.ID)
@@ -473,6 +693,76 @@ func (c FakeClient) CreateCard(name, desc string, list List, labels []Label) (Ca
 	return card, nil
 }
 
+func (c FakeClient) UpdateCard(card Card, name, desc string) (Card, error) {
+	updated, ok := c.Cards[card.ID]
+	if !ok {
+		return Card{}, fmt.Errorf("card %s doesn't exist", card.ID)
+	}
+	updated.Name = name
+	updated.Desc = desc
+	c.Cards[card.ID] = updated
+	for listID, cards := range c.Lists {
+		for i, cc := range cards {
+			if cc.ID == card.ID {
+				c.Lists[listID][i] = updated
+			}
+		}
+	}
+	return updated, nil
+}
+
+func (c FakeClient) MoveCard(card Card, list List) error {
+	moved, ok := c.Cards[card.ID]
+	if !ok {
+		return fmt.Errorf("card %s doesn't exist", card.ID)
+	}
+	if _, ok := c.Lists[list.ID]; !ok {
+		return fmt.Errorf("list %s doesn't exist", list.ID)
+	}
+	for listID, cards := range c.Lists {
+		for i, cc := range cards {
+			if cc.ID == card.ID {
+				c.Lists[listID] = append(cards[:i:i], cards[i+1:]...)
+				break
+			}
+		}
+	}
+	c.Lists[list.ID] = append(c.Lists[list.ID], moved)
+	return nil
+}
+
+func (c FakeClient) AddLabel(card Card, label Label) error {
+	updated, ok := c.Cards[card.ID]
+	if !ok {
+		return fmt.Errorf("card %s doesn't exist", card.ID)
+	}
+	if _, ok := c.Labels[label.ID]; !ok {
+		return fmt.Errorf("label %s doesn't exist", label.ID)
+	}
+	if updated.HasLabel(label) {
+		// as Trello, which answers 400
+		return fmt.Errorf("label %s is already on card %s", label.ID, card.ID)
+	}
+	updated.IDLabels = append(updated.IDLabels[:len(updated.IDLabels):len(updated.IDLabels)], label.ID)
+	c.Cards[card.ID] = updated
+	for listID, cards := range c.Lists {
+		for i, cc := range cards {
+			if cc.ID == card.ID {
+				c.Lists[listID][i] = updated
+			}
+		}
+	}
+	return nil
+}
+
+func (c FakeClient) SetCover(card Card, imageURL string) error {
+	if _, ok := c.Cards[card.ID]; !ok {
+		return fmt.Errorf("card %s doesn't exist", card.ID)
+	}
+	c.Covers[card.ID] = imageURL
+	return nil
+}
+
 func (c FakeClient) CreateComment(text string, card Card) error {
 	if _, ok := c.Cards[card.ID]; !ok {
 		return fmt.Errorf("card %s doesn't exist", card.ID)
@@ -484,11 +774,36 @@ func (c FakeClient) CreateComment(text string, card Card) error {
 	return nil
 }
 
+func (c FakeClient) CreateCustomField(name string, board Board, fieldType CustomFieldType) (CustomField, error) {
+	if _, ok := c.Boards[board.ID]; !ok {
+		return CustomField{}, fmt.Errorf("board %s doesn't exist", board.ID)
+	}
+	field := CustomField{ID: name, Name: name, Type: fieldType}
+	c.CustomFields[field.ID] = fieldType
+	return field, nil
+}
+
+func (c FakeClient) SetCustomFieldValue(card Card, field CustomField, value string) error {
+	if _, ok := c.Cards[card.ID]; !ok {
+		return fmt.Errorf("card %s doesn't exist", card.ID)
+	}
+	if _, ok := c.CustomFields[field.ID]; !ok {
+		return fmt.Errorf("custom field %s doesn't exist", field.ID)
+	}
+	if _, ok := c.CustomFieldValues[card.ID]; !ok {
+		c.CustomFieldValues[card.ID] = make(map[string]string)
+	}
+	c.CustomFieldValues[card.ID][field.Name] = value
+	return nil
+}
+
 func (c FakeClient) GetBoards(_ Organization, _ PermissionLevel) ([]Board, error) {
 	boards := make([]Board, 0, len(c.Boards))
 	for name := range c.Boards {
-		boards = append(boards, Board{ID: name, Name: name})
+		boards = append(boards, Board{ID: name, Name: name, Closed: c.ClosedBoards[name]})
 	}
+	// sorted to be deterministic, as Trello is
+	sort.Slice(boards, func(i, j int) bool { return boards[i].Name < boards[j].Name })
 	return boards, nil
 }
 
@@ -0,0 +1,166 @@
+package trello
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *APIClient {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	transport := NewTransport(srv.Client().Transport)
+	transport.MinBackoff = time.Millisecond
+	transport.MaxBackoff = 5 * time.Millisecond
+	transport.MaxRetries = 2
+	return NewAPIClient(WithURL(srv.URL), WithHTTPClient(&http.Client{Transport: transport}))
+}
+
+func TestAPIClient_RetryRateLimited(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": "API_TOKEN_LIMIT_EXCEEDED"}`))
+			return
+		}
+		assert.Equal(t, "/organizations/bdxio", r.URL.Path)
+		w.Write([]byte(`{"id": "42", "name": "bdxio"}`))
+	})
+
+	org, err := client.GetOrganization("bdxio")
+
+	require.NoError(t, err)
+	assert.Equal(t, Organization{ID: "42", Name: "bdxio"}, org)
+	assert.Equal(t, int32(3), calls)
+}
+
+func TestAPIClient_RetryServerErrorWithBody(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/cards/1/customField/2/item", r.URL.Path)
+		body := make([]byte, r.ContentLength)
+		_, _ = r.Body.Read(body)
+		assert.JSONEq(t, `{"value": {"number": "4.2"}}`, string(body))
+		w.Write([]byte(`{}`))
+	})
+
+	err := client.SetCustomFieldValue(Card{ID: "1"}, CustomField{ID: "2", Type: CustomFieldNumber}, "4.2")
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestAPIClient_NoRetryOnServerErrorForPost(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	_, err := client.CreateCard("A talk", "", List{ID: "1"}, nil)
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestAPIClient_TooManyRetries(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte("API_KEY_LIMIT_EXCEEDED"))
+	})
+
+	_, err := client.CreateList("Sélection", Board{ID: "1"})
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, &APIError{Method: http.MethodPost, Path: "/lists", StatusCode: http.StatusTooManyRequests, Message: "API_KEY_LIMIT_EXCEEDED"}, apiErr)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, int32(3), calls)
+}
+
+func TestAPIClient_NoRetryOnClientError(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("model not found"))
+	})
+
+	_, err := client.GetCards(List{ID: "1"})
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NotErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestAPIClient_MoveCardAndAddLabel(t *testing.T) {
+	var requests []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		w.Write([]byte(`{}`))
+	})
+
+	require.NoError(t, client.MoveCard(Card{ID: "1"}, List{ID: "2"}))
+	require.NoError(t, client.AddLabel(Card{ID: "1"}, Label{ID: "3"}))
+
+	assert.Equal(t, []string{"PUT /cards/1?idList=2&pos=bottom", "POST /cards/1/idLabels?value=3"}, requests)
+}
+
+func TestAPIClient_SetCover(t *testing.T) {
+	var requests []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		w.Write([]byte(`{}`))
+	})
+
+	require.NoError(t, client.SetCover(Card{ID: "1"}, "https://www.avatars.com/u/764359"))
+
+	assert.Equal(t, []string{"POST /cards/1/attachments?setCover=true&url=https%3A%2F%2Fwww.avatars.com%2Fu%2F764359"}, requests)
+}
+
+func TestAPIClient_CreateLabel_Existing(t *testing.T) {
+	var requests []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		w.Write([]byte(`[{"id": "3", "name": "Leala Simard"}]`))
+	})
+
+	label, err := client.CreateLabel("Leala Simard", Board{ID: "1"}, ColorPurple)
+
+	require.NoError(t, err)
+	assert.Equal(t, Label{ID: "3"}, label)
+	assert.Equal(t, []string{"GET /boards/1/labels?limit=1000"}, requests)
+
+	_, ok, err := client.FindLabel("Publié", Board{ID: "1"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Len(t, requests, 1)
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(2, 50*time.Millisecond)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, l.wait(req))
+	}
+
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}